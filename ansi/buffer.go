@@ -139,20 +139,33 @@ func (b *Buffer) Discard() {
 	}
 }
 
+// maxPending limits how many bytes Process waits on for an incomplete escape
+// sequence or control string.
+const maxPending = 1 << 20
+
 // Process bytes written to the internal buffer, decoding runes and escape
 // sequences, and passing them to the given processor.
+//
+// Processing stops at an escape sequence or control string that is cut off
+// by the end of the buffer, to resume once more bytes have been written.
+// However, once more than 1MiB is pending, its introducer is passed to the
+// processor as a rune, and processing continues after it.
 func (b *Buffer) Process(proc Processor) {
 	for p := b.buf.Bytes(); b.off < len(p); {
 		e, a, n := DecodeEscape(p[b.off:])
 		b.off += n
 		if e == 0 {
-			switch r, n := utf8.DecodeRune(p[b.off:]); r {
-			case '\x1b':
-				return
-			default:
-				b.off += n
-				proc.ProcessRune(r)
+			r, n := utf8.DecodeRune(p[b.off:])
+			switch r {
+			case '\x1b', 0x90, 0x9B, 0x9D, 0x9E, 0x9F:
+				// ESC, DCS, CSI, OSC, PM, or APC whose sequence or string is
+				// incomplete; wait for more bytes
+				if len(p)-b.off <= maxPending {
+					return
+				}
 			}
+			b.off += n
+			proc.ProcessRune(r)
 		} else {
 			proc.ProcessEscape(e, a)
 		}
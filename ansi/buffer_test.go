@@ -0,0 +1,46 @@
+package ansi_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jcorbin/anansi/ansi"
+)
+
+type procRecorder struct {
+	escapes []ansi.Escape
+	runes   []rune
+}
+
+func (pr *procRecorder) ProcessEscape(e ansi.Escape, a []byte) { pr.escapes = append(pr.escapes, e) }
+func (pr *procRecorder) ProcessRune(r rune)                    { pr.runes = append(pr.runes, r) }
+
+func TestBuffer_Process(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		writes  []string
+		escapes []ansi.Escape
+		runes   string
+	}{
+		{"split string", []string{"a\x1b]0;ti", "tle\x07b"}, []ansi.Escape{0x9D}, "ab"},
+		{"split escape", []string{"a\x1b", "[Hb"}, []ansi.Escape{ansi.CUP}, "ab"},
+		{"malformed string", []string{"a\x1b]0;caf\xe9\x07b", "c"}, []ansi.Escape{0x9D}, "abc"},
+		{"malformed escape", []string{"a\x1b\xe9", "b"}, []ansi.Escape{ansi.ESC('b')}, "a\ufffd"},
+		{"unterminated string", []string{"a\x1b_", strings.Repeat("x", 1<<20), "b"}, nil,
+			"a\u009f" + strings.Repeat("x", 1<<20) + "b"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf ansi.Buffer
+			var pr procRecorder
+			for _, s := range tc.writes {
+				buf.WriteString(s)
+				buf.Process(&pr)
+				buf.Discard()
+			}
+			assert.Equal(t, tc.escapes, pr.escapes, "expected escapes")
+			assert.Equal(t, tc.runes, string(pr.runes), "expected runes")
+		})
+	}
+}
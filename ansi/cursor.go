@@ -0,0 +1,63 @@
+package ansi
+
+// CursorShape is a cursor shape, as set by DECSCUSR; the zero value is the
+// terminal's default (user configured) shape.
+type CursorShape uint8
+
+// Cursor shapes.
+const (
+	CursorDefault CursorShape = iota
+	CursorBlock
+	CursorUnderline
+	CursorBar
+)
+
+func (shape CursorShape) String() string {
+	switch shape {
+	case CursorDefault:
+		return "default"
+	case CursorBlock:
+		return "block"
+	case CursorUnderline:
+		return "underline"
+	case CursorBar:
+		return "bar"
+	}
+	return "invalid"
+}
+
+// Style returns a DECSCUSR control sequence that sets the cursor shape,
+// blinking or not; blink is ignored for the default shape.
+func (shape CursorShape) Style(blink bool) Seq {
+	if shape == CursorDefault || shape > CursorBar {
+		return DECSCUSR.With('0', ' ')
+	}
+	ps := byte('0' + 2*shape)
+	if blink {
+		ps--
+	}
+	return DECSCUSR.With(ps, ' ')
+}
+
+// DecodeCursorStyle decodes DECSCUSR argument bytes, which must end with the
+// intermediate space that distinguishes it from DECLL.
+func DecodeCursorStyle(a []byte) (shape CursorShape, blink bool, n int, _ error) {
+	if len(a) == 0 || a[len(a)-1] != ' ' {
+		return shape, blink, n, errSyntax
+	}
+	n = len(a)
+	if a = a[:len(a)-1]; len(a) == 0 {
+		return shape, blink, n, nil
+	}
+	ps, _, err := DecodeNumber(a)
+	if err != nil {
+		return shape, blink, n, err
+	}
+	switch {
+	case ps < 0 || ps > 6:
+		return shape, blink, n, errRange
+	case ps == 0:
+		return CursorDefault, false, n, nil
+	}
+	return CursorShape((ps + 1) / 2), ps%2 == 1, n, nil
+}
@@ -33,16 +33,17 @@ func DecodeEscape(p []byte) (e Escape, a []byte, n int) {
 		}
 	case 0x90: // DCS
 		// TODO stricter DCS state machine per vt100.net
-		if sa, sn := decodeString(p[m:]); sn > 0 {
+		if sa, sn := decodeString(p[m:], 0x9C); sn > 0 {
 			return Escape(r), sa, m + sn
 		}
 	case 0x9D: // OSC
-		if sa, sn := decodeString(p[m:]); sn > 0 {
+		// NOTE xterm also accepts BEL as an OSC terminator
+		if sa, sn := decodeString(p[m:], 0x07); sn > 0 {
 			return Escape(r), sa, m + sn
 		}
 		// TODO linux compat handling for OSC
 	case 0x9E, 0x9F: // PM, APC
-		if sa, sn := decodeString(p[m:]); sn > 0 {
+		if sa, sn := decodeString(p[m:], 0x9C); sn > 0 {
 			return Escape(r), sa, m + sn
 		}
 	}
@@ -89,7 +90,10 @@ func decodeESC(p []byte) (e Escape, a []byte, n int) {
 		// decode and process the next rune
 		r, m := utf8.DecodeRune(p[ni:])
 		switch {
-		case r == utf8.RuneError: // may be fixed by more bytes, caller can choose
+		case r == utf8.RuneError && !utf8.FullRune(p[ni:]): // may be fixed by more bytes, caller can choose
+			return 0, nil, ei
+		case r == utf8.RuneError: // invalid byte not part of an escape sequence
+			rshift(m)
 			return 0, nil, ei
 		case r > 0xFF: // higher codepoint not part of an escape sequence
 			rshift(m)
@@ -195,13 +199,16 @@ term:
 	return CSI(p[ni]), a, ni + 1
 }
 
-func decodeString(p []byte) (a []byte, n int) {
+// decodeString decodes a control string argument, terminated by ST (or the
+// given alternate terminator rune). Invalid UTF-8 bytes are kept as part of
+// the argument; only running out of bytes leaves the string incomplete.
+func decodeString(p []byte, term rune) (a []byte, n int) {
 	r, m := decodeRune(p)
 	for {
-		switch r {
-		case utf8.RuneError:
+		switch {
+		case r == utf8.RuneError && !utf8.FullRune(p[n:]):
 			return nil, 0
-		case 0x9C:
+		case r == 0x9C, r == term:
 			return p[:n], n + m
 		}
 		n += m
@@ -298,6 +305,21 @@ func DecodePoint(a []byte) (p Point, n int, err error) {
 	return p, n, err
 }
 
+// DecodeWindowReport decodes the arguments of an XTWINOPS size report,
+// returning its operation code (4, 6, or 8, in response to 14, 16, or 18) and
+// the reported width and height.
+func DecodeWindowReport(a []byte) (op int, size image.Point, n int, err error) {
+	var m int
+	if op, n, err = DecodeNumber(a); err == nil {
+		if size.Y, m, err = DecodeNumber(a[n:]); err == nil {
+			n += m
+			size.X, m, err = DecodeNumber(a[n:])
+		}
+		n += m
+	}
+	return op, size, n, err
+}
+
 // DecodeSGR decodes an SGR attribute value from the given byte buffer; if
 // non-nil error is returned, then n indicates the index of the offending byte.
 func DecodeSGR(a []byte) (attr SGRAttr, n int, _ error) {
@@ -514,6 +536,32 @@ func DecodeMode(private bool, a []byte) (mode Mode, n int, _ error) {
 	return mode, n, err
 }
 
+// DecodeModeReport decodes DECRPM argument bytes, which must end with the
+// intermediate $ that distinguishes it from other CSI y sequences.
+func DecodeModeReport(a []byte) (mode Mode, state ModeState, n int, _ error) {
+	if len(a) == 0 || a[len(a)-1] != '$' {
+		return mode, state, n, errSyntax
+	}
+	n = len(a)
+	a = a[:len(a)-1]
+	private := len(a) > 0 && a[0] == '?'
+	if private {
+		a = a[1:]
+	}
+	mode, m, err := DecodeMode(private, a)
+	if err != nil {
+		return mode, state, n, err
+	}
+	ps, _, err := DecodeNumber(a[m:])
+	if err != nil {
+		return mode, state, n, err
+	}
+	if ps < 0 || ps > int(ModePermanentlyReset) {
+		return mode, state, n, errRange
+	}
+	return mode, ModeState(ps), n, nil
+}
+
 // DecodeCursorCardinal decodes a cardinal cursor move, one of: CUU, CUD, CUF, or CUB.
 func DecodeCursorCardinal(id Escape, a []byte) (d image.Point, _ bool) {
 	switch id {
@@ -2,6 +2,7 @@ package ansi_test
 
 import (
 	"fmt"
+	"image"
 	"testing"
 	"unicode/utf8"
 
@@ -128,6 +129,40 @@ func TestDecodeEscape(t *testing.T) {
 			{anRead{ansi.Escape(0x90), []byte("demo"), 8}, utRead{}},
 			{anRead{}, utRead{')', 1}},
 		}},
+
+		{"(\x1b]0;demo\x1b\\)", []ev{
+			{anRead{}, utRead{'(', 1}},
+			{anRead{ansi.Escape(0x9D), []byte("0;demo"), 10}, utRead{}},
+			{anRead{}, utRead{')', 1}},
+		}},
+
+		{"(\x1b]0;demo\x07)", []ev{
+			{anRead{}, utRead{'(', 1}},
+			{anRead{ansi.Escape(0x9D), []byte("0;demo"), 9}, utRead{}},
+			{anRead{}, utRead{')', 1}},
+		}},
+
+		{"(\x1b]0;caf\xe9\x07)", []ev{
+			{anRead{}, utRead{'(', 1}},
+			{anRead{ansi.Escape(0x9D), []byte("0;caf\xe9"), 9}, utRead{}},
+			{anRead{}, utRead{')', 1}},
+		}},
+
+		{"(\x1b]0;caf\xc3", []ev{
+			{anRead{}, utRead{'(', 1}},
+			{anRead{}, utRead{0x9D, 2}},
+			{anRead{}, utRead{'0', 1}},
+			{anRead{}, utRead{';', 1}},
+			{anRead{}, utRead{'c', 1}},
+			{anRead{}, utRead{'a', 1}},
+			{anRead{}, utRead{'f', 1}},
+			{anRead{}, utRead{utf8.RuneError, 1}},
+		}},
+
+		{"\x1b\xe9=", []ev{
+			{anRead{}, utRead{utf8.RuneError, 1}},
+			{anRead{ansi.Escape(0xEF3D), nil, 2}, utRead{}},
+		}},
 	}
 
 	const sanity = 100
@@ -200,6 +235,52 @@ func TestDecodeNumber(t *testing.T) {
 	}
 }
 
+func TestDecodeWindowReport(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		op   int
+		size image.Point
+	}{
+		{"\x1b[4;600;800t", 4, image.Pt(800, 600)},
+		{"\x1b[6;16;8t", 6, image.Pt(8, 16)},
+		{"\x1b[8;24;80t", 8, image.Pt(80, 24)},
+	} {
+		t.Run(tc.in[1:], func(t *testing.T) {
+			e, a, _ := ansi.DecodeEscape([]byte(tc.in))
+			require.Equal(t, ansi.XTWINOPS, e)
+			op, size, n, err := ansi.DecodeWindowReport(a)
+			require.NoError(t, err)
+			assert.Equal(t, len(a), n)
+			assert.Equal(t, tc.op, op)
+			assert.Equal(t, tc.size, size)
+		})
+	}
+}
+
+func TestModeReport(t *testing.T) {
+	assert.Equal(t, "\x1b[?2026$p", string(ansi.ModeSyncOutput.Request().AppendTo(nil)))
+	assert.Equal(t, "\x1b[4$p", string(ansi.ModeInsert.Request().AppendTo(nil)))
+	for _, tc := range []struct {
+		in    string
+		mode  ansi.Mode
+		state ansi.ModeState
+	}{
+		{"\x1b[?2026;2$y", ansi.ModeSyncOutput, ansi.ModeIsReset},
+		{"\x1b[?25;1$y", ansi.ShowCursor, ansi.ModeIsSet},
+		{"\x1b[4;0$y", ansi.ModeInsert, ansi.ModeNotRecognized},
+	} {
+		t.Run(tc.in[1:], func(t *testing.T) {
+			e, a, _ := ansi.DecodeEscape([]byte(tc.in))
+			require.Equal(t, ansi.DECRPM, e)
+			mode, state, n, err := ansi.DecodeModeReport(a)
+			require.NoError(t, err)
+			assert.Equal(t, len(a), n)
+			assert.Equal(t, tc.mode, mode)
+			assert.Equal(t, tc.state, state)
+		})
+	}
+}
+
 func TestDecodeSGR_roundtrips(t *testing.T) {
 	for _, tc := range []struct {
 		attr ansi.SGRAttr
@@ -295,3 +376,32 @@ func TestPoint_roundtrip(t *testing.T) {
 		})
 	}
 }
+
+func TestCursorStyle_roundtrip(t *testing.T) {
+	for _, tc := range []struct {
+		shape ansi.CursorShape
+		blink bool
+		seq   string
+	}{
+		{ansi.CursorDefault, false, "\x1b[0 q"},
+		{ansi.CursorBlock, true, "\x1b[1 q"},
+		{ansi.CursorBlock, false, "\x1b[2 q"},
+		{ansi.CursorUnderline, true, "\x1b[3 q"},
+		{ansi.CursorUnderline, false, "\x1b[4 q"},
+		{ansi.CursorBar, true, "\x1b[5 q"},
+		{ansi.CursorBar, false, "\x1b[6 q"},
+	} {
+		t.Run(tc.seq[1:], func(t *testing.T) {
+			b := tc.shape.Style(tc.blink).AppendTo(nil)
+			assert.Equal(t, tc.seq, string(b))
+			e, a, n := ansi.DecodeEscape(b)
+			require.Equal(t, ansi.DECSCUSR, e)
+			require.Equal(t, len(b), n)
+			shape, blink, n, err := ansi.DecodeCursorStyle(a)
+			require.NoError(t, err)
+			require.Equal(t, len(a), n)
+			assert.Equal(t, tc.shape, shape)
+			assert.Equal(t, tc.blink, blink)
+		})
+	}
+}
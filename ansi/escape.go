@@ -105,7 +105,9 @@ func (id Escape) String() string {
 
 // IsCharacterSetControl returns true if the escape identifier is a character
 // control rune, or an character set control escape sequence. Such controls can
-// be ignored in a modern UTF-8 terminal.
+// mostly be ignored in a modern UTF-8 terminal, except that programs still use
+// them to draw lines and boxes (e.g. the DEC Special Graphics set); see
+// anansi.ScreenState for translation.
 func (id Escape) IsCharacterSetControl() bool {
 	switch id {
 	case
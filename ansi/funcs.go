@@ -412,6 +412,17 @@ var (
 	// send ED.With('2') and CUP.
 	SoftReset = DECSTR.With('!')
 
+	/*DECRQM Request mode, distinguished from DECSTR by an intermediate $
+	  [4$p     = Request the state of ANSI mode 4 (IRM)
+	  [?2026$p = Request the state of DEC private mode 2026
+	  See Mode.Request; the reply is a DECRPM. */
+	DECRQM = CSI('p')
+
+	/*DECRPM Report mode (from terminal to host), in reply to DECRQM
+	  [?2026;2$y = DEC private mode 2026 is recognized, and reset
+	  See DecodeModeReport. */
+	DECRPM = CSI('y')
+
 	/*DECLL Load LEDs
 	  [0q           = Turn off all
 	  [?1;4q        = turns on L1 and L4, etc
@@ -422,6 +433,14 @@ var (
 	  [2"q          = DECSCA - designate set as erasable */
 	DECLL = CSI('q')
 
+	/*DECSCUSR Set cursor style, distinguished from DECLL by an intermediate space
+	  [0 q = Default (user configured) style
+	  [1 q = Blinking block, [2 q = Steady block
+	  [3 q = Blinking underline, [4 q = Steady underline
+	  [5 q = Blinking bar, [6 q = Steady bar
+	  See CursorShape and DecodeCursorStyle. */
+	DECSCUSR = CSI('q')
+
 	/*DECSTBM Set top and bottom margins (scroll region on VT100)
 	  [4;20r = Set top margin at line 4 and bottom at line 20 */
 	DECSTBM = CSI('r')
@@ -434,6 +453,13 @@ var (
 	  [66t = Paper has 66 lines (11 inches at 6 per inch) */
 	DECSLPP = CSI('t')
 
+	/*XTWINOPS Window manipulation and reports, distinguished from DECSLPP by
+	  parameters under 24; notably (see DecodeWindowReport):
+	  [14t = Report text area size in pixels, as [4;height;width t
+	  [16t = Report cell size in pixels, as [6;height;width t
+	  [18t = Report text area size in characters, as [8;rows;columns t */
+	XTWINOPS = CSI('t')
+
 	/*DECSHTS Set many horizontal tab stops at once on LA100
 	  [9;17;25;33;41;49;57;65;73;81u = Set standard tab stops */
 	DECSHTS = CSI('u')
@@ -0,0 +1,306 @@
+package ansi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/png"
+	"strconv"
+)
+
+// ImageProtocols is a set of inline image protocols supported by a terminal,
+// as determined by ProbeReply and ProbeEnv.
+type ImageProtocols uint8
+
+// Inline image protocols.
+const (
+	// ImageSixel is the DEC sixel protocol, as drawn by AppendSixel; it's
+	// advertised by primary device attributes.
+	ImageSixel ImageProtocols = 1 << iota
+
+	// ImageITerm2 is iTerm2's inline image protocol, as drawn by
+	// AppendITerm2Image; it has no query, so is only detected by ProbeEnv.
+	ImageITerm2
+
+	// ImageKitty is the kitty graphics protocol, as drawn by
+	// AppendKittyImage; it's detected by a query.
+	ImageKitty
+)
+
+var errNoImageProtocol = errors.New("no inline image protocol supported")
+
+// kittyProbeID is the image id used by the kitty graphics support query.
+const kittyProbeID = "31"
+
+// AppendImageProbe appends queries for inline image support: a kitty graphics
+// query, followed by a primary device attributes (DA) request; since all
+// terminals answer the latter, its reply marks the end of the probe. Replies
+// should then be passed to ProbeReply.
+func AppendImageProbe(p []byte) []byte {
+	p = append(p, "\x1b_Gi="+kittyProbeID+",s=1,v=1,a=q,t=d,f=24;AAAA\x1b\\"...)
+	return DA.seq().AppendTo(p)
+}
+
+// ProbeReply adds any protocols indicated by a decoded reply to
+// AppendImageProbe, returning true when the probe is complete.
+func (ps *ImageProtocols) ProbeReply(e Escape, a []byte) (done bool) {
+	switch e {
+	case Escape(0x9F): // APC
+		if bytes.HasPrefix(a, []byte("Gi="+kittyProbeID+";")) && bytes.HasSuffix(a, []byte(";OK")) {
+			*ps |= ImageKitty
+		}
+	case DA:
+		if len(a) == 0 || a[0] != '?' {
+			return false
+		}
+		for _, attr := range bytes.Split(a[1:], []byte(";")) {
+			if string(attr) == "4" {
+				*ps |= ImageSixel
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// ProbeEnv adds any protocols indicated by environment variables, as looked
+// up by getenv (e.g. os.Getenv), that aren't detectable by AppendImageProbe.
+func (ps *ImageProtocols) ProbeEnv(getenv func(string) string) {
+	switch getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm":
+		*ps |= ImageITerm2
+	}
+	if getenv("LC_TERMINAL") == "iTerm2" {
+		*ps |= ImageITerm2
+	}
+}
+
+// Best returns the most capable supported protocol: kitty, then iTerm2, then
+// sixel; returns 0 if none are supported.
+func (ps ImageProtocols) Best() ImageProtocols {
+	for _, p := range []ImageProtocols{ImageKitty, ImageITerm2, ImageSixel} {
+		if ps&p != 0 {
+			return p
+		}
+	}
+	return 0
+}
+
+// AppendImage appends the image encoded for the Best supported protocol,
+// sized to cover the given number of cells. Sixel images are drawn at their
+// pixel size, so should be scaled to match cells by the caller, and are
+// quantized to the given palette (see AppendSixel).
+func (ps ImageProtocols) AppendImage(p []byte, img image.Image, cells image.Point, pal Palette) ([]byte, error) {
+	switch ps.Best() {
+	case ImageKitty:
+		return AppendKittyImage(p, img, 0, cells)
+	case ImageITerm2:
+		return AppendITerm2Image(p, img, cells)
+	case ImageSixel:
+		return AppendSixel(p, img, pal), nil
+	}
+	return p, errNoImageProtocol
+}
+
+// AppendSixel appends a sixel DCS string that draws the image, quantizing its
+// colors to the given palette (Palette8 if empty); only used palette colors
+// are defined as color registers. Transparent pixels are left undrawn.
+func AppendSixel(p []byte, img image.Image, pal Palette) []byte {
+	if len(pal) == 0 {
+		pal = Palette8
+	}
+	r := img.Bounds()
+	w, h := r.Dx(), r.Dy()
+
+	// quantize pixels into color registers, -1 for transparent
+	regs := make([]int, w*h)
+	palReg := make(map[int]int)
+	cache := make(map[SGRColor]int)
+	var used []SGRColor
+	for i := range regs {
+		cr, cg, cb, ca := img.At(r.Min.X+i%w, r.Min.Y+i/w).RGBA()
+		if ca < 0x8000 {
+			regs[i] = -1
+			continue
+		}
+		c := RGBA(cr, cg, cb, ca)
+		pi, ok := cache[c]
+		if !ok {
+			pi = pal.Index(c)
+			cache[c] = pi
+		}
+		reg, ok := palReg[pi]
+		if !ok {
+			reg = len(used)
+			palReg[pi] = reg
+			used = append(used, pal[pi])
+		}
+		regs[i] = reg
+	}
+
+	p = append(p, "\x1bP0;1;0q\"1;1;"...)
+	p = strconv.AppendInt(p, int64(w), 10)
+	p = append(p, ';')
+	p = strconv.AppendInt(p, int64(h), 10)
+	for reg, c := range used {
+		cr, cg, cb := c.RGB()
+		p = append(p, '#')
+		p = strconv.AppendInt(p, int64(reg), 10)
+		p = append(p, ";2"...)
+		for _, v := range [3]uint8{cr, cg, cb} {
+			p = append(p, ';')
+			p = strconv.AppendInt(p, (int64(v)*100+127)/255, 10)
+		}
+	}
+
+	row := make([]byte, w)
+	for y0 := 0; y0 < h; y0 += 6 {
+		if y0 > 0 {
+			p = append(p, '-')
+		}
+		first := true
+		for reg := range used {
+			set := false
+			for x := 0; x < w; x++ {
+				bits := byte(0)
+				for dy := 0; dy < 6 && y0+dy < h; dy++ {
+					if regs[(y0+dy)*w+x] == reg {
+						bits |= 1 << uint(dy)
+					}
+				}
+				row[x] = '?' + bits
+				set = set || bits != 0
+			}
+			if !set {
+				continue
+			}
+			if !first {
+				p = append(p, '$')
+			}
+			first = false
+			p = append(p, '#')
+			p = strconv.AppendInt(p, int64(reg), 10)
+			p = appendSixelRow(p, bytes.TrimRight(row, "?"))
+		}
+	}
+	return append(p, "\x1b\\"...)
+}
+
+// appendSixelRow appends sixel data, run-length encoding any repeats.
+func appendSixelRow(p, row []byte) []byte {
+	for i := 0; i < len(row); {
+		j := i + 1
+		for j < len(row) && row[j] == row[i] {
+			j++
+		}
+		if n := j - i; n > 3 {
+			p = append(p, '!')
+			p = strconv.AppendInt(p, int64(n), 10)
+			p = append(p, row[i])
+		} else {
+			p = append(p, row[i:j]...)
+		}
+		i = j
+	}
+	return p
+}
+
+// AppendITerm2Image appends an iTerm2 inline image OSC string that draws the
+// image, encoded as PNG, stretched to cover the given number of cells; either
+// dimension may be 0 to leave it to the terminal (preserving aspect ratio).
+func AppendITerm2Image(p []byte, img image.Image, cells image.Point) ([]byte, error) {
+	data, err := encodePNG(img)
+	if err != nil {
+		return p, err
+	}
+	p = append(p, "\x1b]1337;File=inline=1;size="...)
+	p = strconv.AppendInt(p, int64(len(data)), 10)
+	if cells.X > 0 {
+		p = append(p, ";width="...)
+		p = strconv.AppendInt(p, int64(cells.X), 10)
+	}
+	if cells.Y > 0 {
+		p = append(p, ";height="...)
+		p = strconv.AppendInt(p, int64(cells.Y), 10)
+	}
+	if cells.X > 0 && cells.Y > 0 {
+		p = append(p, ";preserveAspectRatio=0"...)
+	}
+	p = append(p, ':')
+	p = appendBase64(p, data)
+	return append(p, '\a'), nil
+}
+
+// kittyChunkSize is the maximum size of each kitty graphics payload chunk.
+const kittyChunkSize = 4096
+
+// AppendKittyImage appends kitty graphics APC strings that transmit and
+// display the image, encoded as PNG, at the cursor, scaled to cover the given
+// number of cells (either dimension may be 0 to leave it to the terminal).
+// The cursor isn't moved, and the terminal is asked not to reply. A non-zero
+// id allows the image to be later deleted by AppendKittyDelete.
+func AppendKittyImage(p []byte, img image.Image, id uint32, cells image.Point) ([]byte, error) {
+	data, err := encodePNG(img)
+	if err != nil {
+		return p, err
+	}
+	payload := appendBase64(nil, data)
+	for i := 0; i == 0 || i < len(payload); i += kittyChunkSize {
+		j := i + kittyChunkSize
+		if j > len(payload) {
+			j = len(payload)
+		}
+		p = append(p, "\x1b_G"...)
+		if i == 0 {
+			p = append(p, "a=T,f=100,q=2,C=1"...)
+			p = appendKittyKey(p, 'i', int(id))
+			p = appendKittyKey(p, 'c', cells.X)
+			p = appendKittyKey(p, 'r', cells.Y)
+			p = append(p, ',')
+		}
+		if j < len(payload) {
+			p = append(p, "m=1;"...)
+		} else {
+			p = append(p, "m=0;"...)
+		}
+		p = append(p, payload[i:j]...)
+		p = append(p, "\x1b\\"...)
+	}
+	return p, nil
+}
+
+// AppendKittyDelete appends a kitty graphics APC string that deletes the
+// image with the given id, freeing its data.
+func AppendKittyDelete(p []byte, id uint32) []byte {
+	p = append(p, "\x1b_Ga=d,d=I,q=2"...)
+	p = appendKittyKey(p, 'i', int(id))
+	return append(p, "\x1b\\"...)
+}
+
+func appendKittyKey(p []byte, key byte, n int) []byte {
+	if n <= 0 {
+		return p
+	}
+	p = append(p, ',', key, '=')
+	return strconv.AppendInt(p, int64(n), 10)
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func appendBase64(p, data []byte) []byte {
+	n := base64.StdEncoding.EncodedLen(len(data))
+	if need := len(p) + n; need > cap(p) {
+		np := make([]byte, len(p), need)
+		copy(np, p)
+		p = np
+	}
+	base64.StdEncoding.Encode(p[len(p):len(p)+n], data)
+	return p[:len(p)+n]
+}
@@ -0,0 +1,126 @@
+package ansi_test
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jcorbin/anansi/ansi"
+)
+
+func TestImageProtocols_probe(t *testing.T) {
+	probe := string(ansi.AppendImageProbe(nil))
+	assert.True(t, strings.HasPrefix(probe, "\x1b_G"), "expected kitty query first")
+	assert.True(t, strings.HasSuffix(probe, "\x1b[c"), "expected DA request last")
+
+	for _, tc := range []struct {
+		name    string
+		replies []string
+		env     map[string]string
+		expect  ansi.ImageProtocols
+		best    ansi.ImageProtocols
+	}{
+		{
+			name:    "none",
+			replies: []string{"\x1b[?62;22c"},
+		},
+		{
+			name:    "sixel",
+			replies: []string{"\x1b[?62;4;22c"},
+			expect:  ansi.ImageSixel,
+			best:    ansi.ImageSixel,
+		},
+		{
+			name:    "kitty",
+			replies: []string{"\x1b_Gi=31;OK\x1b\\", "\x1b[?62;4c"},
+			expect:  ansi.ImageKitty | ansi.ImageSixel,
+			best:    ansi.ImageKitty,
+		},
+		{
+			name:    "iterm2",
+			replies: []string{"\x1b[?62;4c"},
+			env:     map[string]string{"TERM_PROGRAM": "iTerm.app"},
+			expect:  ansi.ImageITerm2 | ansi.ImageSixel,
+			best:    ansi.ImageITerm2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var ps ansi.ImageProtocols
+			ps.ProbeEnv(func(name string) string { return tc.env[name] })
+			done := false
+			for _, reply := range tc.replies {
+				e, a, n := ansi.DecodeEscape([]byte(reply))
+				require.Equal(t, len(reply), n, "expected to decode %q", reply)
+				require.False(t, done, "unexpected reply after probe done")
+				done = ps.ProbeReply(e, a)
+			}
+			assert.True(t, done, "expected probe to be done")
+			assert.Equal(t, tc.expect, ps)
+			assert.Equal(t, tc.best, ps.Best())
+		})
+	}
+}
+
+func TestImageEncoders(t *testing.T) {
+	var (
+		red   = color.RGBA{0xff, 0, 0, 0xff}
+		blue  = color.RGBA{0, 0, 0xff, 0xff}
+		clear = color.RGBA{}
+	)
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for i, c := range []color.RGBA{
+		red, red, red, red,
+		blue, blue, clear, clear,
+	} {
+		img.SetRGBA(i%4, i/4, c)
+	}
+
+	t.Run("sixel", func(t *testing.T) {
+		pal := ansi.Palette{ansi.RGB(0, 0, 0), ansi.RGB(0xff, 0, 0), ansi.RGB(0, 0, 0xff)}
+		assert.Equal(t,
+			"\x1bP0;1;0q\"1;1;4;2#0;2;100;0;0#1;2;0;0;100#0!4@$#1AA\x1b\\",
+			string(ansi.AppendSixel(nil, img, pal)))
+	})
+
+	t.Run("iterm2", func(t *testing.T) {
+		b, err := ansi.AppendITerm2Image(nil, img, image.Pt(4, 1))
+		require.NoError(t, err)
+		s := string(b)
+		assert.True(t, strings.HasPrefix(s, "\x1b]1337;File=inline=1;size="), "expected OSC 1337 prefix")
+		assert.Contains(t, s, ";width=4;height=1;preserveAspectRatio=0:iVBORw0KGgo")
+		assert.True(t, strings.HasSuffix(s, "\a"), "expected BEL terminator")
+	})
+
+	t.Run("kitty", func(t *testing.T) {
+		b, err := ansi.AppendKittyImage(nil, img, 7, image.Pt(4, 1))
+		require.NoError(t, err)
+		s := string(b)
+		assert.True(t, strings.HasPrefix(s, "\x1b_Ga=T,f=100,q=2,C=1,i=7,c=4,r=1,m=0;iVBORw0KGgo"), "expected kitty APC prefix")
+		assert.True(t, strings.HasSuffix(s, "\x1b\\"), "expected ST terminator")
+		assert.Equal(t, "\x1b_Ga=d,d=I,q=2,i=7\x1b\\", string(ansi.AppendKittyDelete(nil, 7)))
+	})
+
+	t.Run("kitty chunks", func(t *testing.T) {
+		big := image.NewRGBA(image.Rect(0, 0, 64, 64))
+		rand.New(rand.NewSource(1)).Read(big.Pix)
+		b, err := ansi.AppendKittyImage(nil, big, 0, image.ZP)
+		require.NoError(t, err)
+		chunks := strings.Split(strings.TrimSuffix(string(b), "\x1b\\"), "\x1b\\")
+		require.True(t, len(chunks) > 1, "expected multiple chunks")
+		for i, chunk := range chunks {
+			switch {
+			case i == 0:
+				assert.True(t, strings.HasPrefix(chunk, "\x1b_Ga=T,f=100,q=2,C=1,m=1;"), "[%d] expected first chunk", i)
+			case i < len(chunks)-1:
+				assert.True(t, strings.HasPrefix(chunk, "\x1b_Gm=1;"), "[%d] expected middle chunk", i)
+			default:
+				assert.True(t, strings.HasPrefix(chunk, "\x1b_Gm=0;"), "[%d] expected last chunk", i)
+			}
+		}
+	})
+}
@@ -1,5 +1,7 @@
 package ansi
 
+import "strconv"
+
 // Mode is an ANSI terminal mode constant.
 type Mode uint64
 
@@ -24,12 +26,48 @@ func (mode Mode) Reset() Seq {
 	return RMprivate.WithInts(int(mode & ^ModePrivate))
 }
 
+// Request returns a control sequence that requests the mode's state from the
+// terminal (DECRQM); the reply may be decoded by DecodeModeReport.
+func (mode Mode) Request() Seq {
+	var tmp [24]byte
+	arg := tmp[:0]
+	if mode&ModePrivate != 0 {
+		arg = append(arg, '?')
+	}
+	arg = strconv.AppendInt(arg, int64(mode&^ModePrivate), 10)
+	return DECRQM.With(append(arg, '$')...)
+}
+
+// ModeState is the state of a mode, as reported by DECRPM.
+type ModeState uint8
+
+// Mode states
+const (
+	ModeNotRecognized ModeState = iota
+	ModeIsSet
+	ModeIsReset
+	ModePermanentlySet
+	ModePermanentlyReset
+)
+
+// standard mode constants
+const (
+	ModeInsert  Mode = 4  // IRM
+	ModeNewline Mode = 20 // LNM
+)
+
 // private mode constants
 // TODO more coverage
 const (
 	ModeMouseX10 Mode = 9
 )
 
+// DEC private mode constants
+const (
+	ModeOrigin   = ModePrivate | 6 // DECOM
+	ModeAutoWrap = ModePrivate | 7 // DECAWM
+)
+
 // xterm mode constants; see http://invisible-island.net/xterm/ctlseqs/ctlseqs.html.
 const (
 	ModeMouseVt200          = ModePrivate | 1000
@@ -45,9 +83,18 @@ const (
 
 	ModeAlternateScroll = ModePrivate | 1007
 	ModeMetaReporting   = ModePrivate | 1036
-	ModeAlternateScreen = ModePrivate | 1049
+
+	ModeAlternateBuffer      = ModePrivate | 47
+	ModeAlternateBufferClear = ModePrivate | 1047
+	ModeSaveCursor           = ModePrivate | 1048
+	ModeAlternateScreen      = ModePrivate | 1049
 )
 
+// ModeSyncOutput is the synchronized output mode: while set, the terminal
+// defers drawing any output, so that whole frames are drawn at once; see
+// SyncProtocol.
+const ModeSyncOutput = ModePrivate | 2026
+
 // TODO http://www.disinterest.org/resource/MUD-Dev/1997q1/000244.html and others
 const (
 	ShowCursor = ModePrivate | 25
@@ -0,0 +1,64 @@
+package ansi
+
+// SyncProtocol is a protocol for synchronized output, which brackets each
+// frame of output so that the terminal draws it all at once, avoiding tearing.
+type SyncProtocol uint8
+
+// Synchronized output protocols.
+const (
+	// SyncNone disables synchronized output.
+	SyncNone SyncProtocol = iota
+
+	// SyncMode sets and resets ModeSyncOutput around each frame; its support
+	// is detected by requesting the mode's state (see ProbeReply).
+	SyncMode
+
+	// SyncDCS sends the DCS =1s and =2s strings around each frame, as first
+	// implemented by iTerm2; it has no query, so must be enabled knowingly.
+	SyncDCS
+)
+
+// AppendSyncProbe appends a request for the state of ModeSyncOutput, whose
+// reply should be passed to ProbeReply.
+func AppendSyncProbe(p []byte) []byte {
+	return ModeSyncOutput.Request().AppendTo(p)
+}
+
+// ProbeReply sets the protocol to SyncMode if a decoded reply to
+// AppendSyncProbe shows that the terminal supports ModeSyncOutput, returning
+// true if the reply was a report for that mode.
+func (sp *SyncProtocol) ProbeReply(e Escape, a []byte) bool {
+	if e != DECRPM {
+		return false
+	}
+	mode, state, _, err := DecodeModeReport(a)
+	if err != nil || mode != ModeSyncOutput {
+		return false
+	}
+	if state == ModeIsSet || state == ModeIsReset {
+		*sp = SyncMode
+	}
+	return true
+}
+
+// AppendBegin appends the control sequence that begins a frame.
+func (sp SyncProtocol) AppendBegin(p []byte) []byte {
+	switch sp {
+	case SyncMode:
+		return ModeSyncOutput.Set().AppendTo(p)
+	case SyncDCS:
+		return append(p, "\x1bP=1s\x1b\\"...)
+	}
+	return p
+}
+
+// AppendEnd appends the control sequence that ends a frame.
+func (sp SyncProtocol) AppendEnd(p []byte) []byte {
+	switch sp {
+	case SyncMode:
+		return ModeSyncOutput.Reset().AppendTo(p)
+	case SyncDCS:
+		return append(p, "\x1bP=2s\x1b\\"...)
+	}
+	return p
+}
@@ -0,0 +1,43 @@
+package ansi_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jcorbin/anansi/ansi"
+)
+
+func TestSyncProtocol(t *testing.T) {
+	assert.Equal(t, "\x1b[?2026$p", string(ansi.AppendSyncProbe(nil)))
+
+	for _, tc := range []struct {
+		name   string
+		reply  string
+		expect ansi.SyncProtocol
+		begin  string
+		end    string
+	}{
+		{"supported", "\x1b[?2026;2$y", ansi.SyncMode, "\x1b[?2026h", "\x1b[?2026l"},
+		{"unrecognized", "\x1b[?2026;0$y", ansi.SyncNone, "", ""},
+		{"permanent", "\x1b[?2026;4$y", ansi.SyncNone, "", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var sp ansi.SyncProtocol
+			e, a, _ := ansi.DecodeEscape([]byte(tc.reply))
+			require.True(t, sp.ProbeReply(e, a), "expected reply to be consumed")
+			assert.Equal(t, tc.expect, sp)
+			assert.Equal(t, tc.begin, string(sp.AppendBegin(nil)))
+			assert.Equal(t, tc.end, string(sp.AppendEnd(nil)))
+		})
+	}
+
+	var sp ansi.SyncProtocol
+	e, a, _ := ansi.DecodeEscape([]byte("\x1b[?25;1$y"))
+	assert.False(t, sp.ProbeReply(e, a), "expected other mode report to be ignored")
+
+	sp = ansi.SyncDCS
+	assert.Equal(t, "\x1bP=1s\x1b\\", string(sp.AppendBegin(nil)))
+	assert.Equal(t, "\x1bP=2s\x1b\\", string(sp.AppendEnd(nil)))
+}
@@ -4,22 +4,62 @@ import (
 	"image"
 	"os"
 	"syscall"
+	"time"
 	"unsafe"
 )
 
 // Attr implements Context-ual manipulation and interrogation of terminal
 // state, using the termios IOCTLs and ANSI control sequences where possible.
+//
+// Settings made before Enter are applied on top of the terminal's original
+// attributes once entered, and immediately thereafter; Exit restores the
+// original attributes.
 type Attr struct {
-	orig syscall.Termios
-	cur  syscall.Termios
-	raw  bool
-	echo bool
+	// Drain causes changes to be applied only after any pending output has
+	// been transmitted (TCSADRAIN), rather than immediately (TCSANOW).
+	Drain bool
+
+	orig   syscall.Termios
+	cur    syscall.Termios
+	raw    bool
+	cbreak bool
+	echo   bool
+	post   flagOverride // output post-processing
+	flow   flagOverride // XON/XOFF flow control
+	timing bool         // vmin and vtime set by SetReadMin
+	vmin   uint8
+	vtime  uint8
 
 	f *os.File
 }
 
+// flagOverride is a tri-state setting that either leaves a termios flag as
+// determined by the other settings, or forces it on or off.
+type flagOverride uint8
+
+const (
+	flagDefault flagOverride = iota
+	flagOn
+	flagOff
+)
+
+func overrideFlag(on bool) flagOverride {
+	if on {
+		return flagOn
+	}
+	return flagOff
+}
+
 // Size reads and returns the current terminal size.
 func (at *Attr) Size() (size image.Point, err error) {
+	size, _, err = at.WindowSize()
+	return size, err
+}
+
+// WindowSize reads and returns the current terminal size, and the size of its
+// text area in pixels; the latter is zero if the terminal doesn't report it,
+// in which case it may be queried by ansi.XTWINOPS.
+func (at *Attr) WindowSize() (size, pixels image.Point, err error) {
 	var dim struct {
 		rows    uint16
 		cols    uint16
@@ -30,25 +70,55 @@ func (at *Attr) Size() (size image.Point, err error) {
 	if err == nil {
 		size.X = int(dim.cols)
 		size.Y = int(dim.rows)
+		pixels.X = int(dim.xpixels)
+		pixels.Y = int(dim.ypixels)
 	}
-	return size, err
+	return size, pixels, err
+}
+
+// CellSize reads and returns the current size of a terminal cell in pixels;
+// it is zero if the terminal doesn't report its pixel size.
+func (at *Attr) CellSize() (image.Point, error) {
+	size, pixels, err := at.WindowSize()
+	return CellSize(size, pixels), err
+}
+
+// CellSize returns the size of a terminal cell in pixels, given the terminal
+// size and the size of its text area in pixels; it is zero if either is.
+func CellSize(size, pixels image.Point) (cell image.Point) {
+	if size.X > 0 && size.Y > 0 {
+		cell = image.Pt(pixels.X/size.X, pixels.Y/size.Y)
+	}
+	if cell.X == 0 || cell.Y == 0 {
+		return image.ZP
+	}
+	return cell
 }
 
 // SetRaw controls whether the terminal should be in raw mode.
 //
 // Raw mode is suitable for full-screen terminal user interfaces, eliminating
 // keyboard shortcuts for job control, echo, line buffering, and escape key
-// debouncing.
+// debouncing. It also disables output post-processing (e.g. LF to CRLF
+// translation) and flow control, unless overridden by SetOutputProcessing
+// or SetFlowControl.
 func (at *Attr) SetRaw(raw bool) error {
 	if raw == at.raw {
 		return nil
 	}
 	at.raw = raw
-	if at.f != nil {
-		at.cur = at.modifyTermios(at.orig)
-		return at.setAttr(at.cur)
+	return at.apply()
+}
+
+// SetCBreak controls whether the terminal should be in cbreak mode, which is
+// like raw mode, except that signal generating keys, like Ctrl-C and Ctrl-Z,
+// still work; output post-processing and flow control are also left as-is.
+func (at *Attr) SetCBreak(cbreak bool) error {
+	if cbreak == at.cbreak {
+		return nil
 	}
-	return nil
+	at.cbreak = cbreak
+	return at.apply()
 }
 
 // SetEcho toggles input echoing mode, which is off by default in raw mode, and
@@ -58,30 +128,101 @@ func (at *Attr) SetEcho(echo bool) error {
 		return nil
 	}
 	at.echo = echo
-	if at.f != nil {
-		if echo {
-			at.cur.Lflag |= syscall.ECHO
-		} else {
-			at.cur.Lflag &^= syscall.ECHO
-		}
-		return at.setAttr(at.cur)
+	return at.apply()
+}
+
+// SetOutputProcessing controls output post-processing (OPOST), overriding
+// whether raw mode disables it.
+func (at *Attr) SetOutputProcessing(post bool) error {
+	if flag := overrideFlag(post); flag != at.post {
+		at.post = flag
+		return at.apply()
+	}
+	return nil
+}
+
+// OutputProcessing returns true if output post-processing (OPOST) is in
+// effect once entered, as determined by the original attributes, raw mode,
+// and SetOutputProcessing; e.g. LF then typically also implies CR.
+func (at *Attr) OutputProcessing() bool {
+	return at.modifyTermios(at.orig).Oflag&syscall.OPOST != 0
+}
+
+// SetFlowControl controls XON/XOFF (Ctrl-S/Ctrl-Q) flow control of output,
+// overriding whether raw mode disables it.
+func (at *Attr) SetFlowControl(flow bool) error {
+	if flag := overrideFlag(flow); flag != at.flow {
+		at.flow = flag
+		return at.apply()
 	}
 	return nil
 }
 
+// SetReadMin controls how reads complete under raw and cbreak modes: a read
+// waits for at least min bytes, or until timeout (which has a resolution of
+// tenths of a second, up to 25.5 seconds) has passed since the last byte was
+// received; a zero min with a non-zero timeout waits for any byte, up to the
+// timeout, and zero for both makes reads non-blocking. The default is min 1
+// with no timeout: reads block until at least one byte is available.
+func (at *Attr) SetReadMin(min int, timeout time.Duration) error {
+	vmin, vtime := clampCC(min), clampCC(int(timeout/(100*time.Millisecond)))
+	if at.timing && vmin == at.vmin && vtime == at.vtime {
+		return nil
+	}
+	at.timing, at.vmin, at.vtime = true, vmin, vtime
+	return at.apply()
+}
+
+func clampCC(n int) uint8 {
+	if n < 0 {
+		return 0
+	}
+	if n > 255 {
+		return 255
+	}
+	return uint8(n)
+}
+
+// apply any modified termios attributes, if entered.
+func (at *Attr) apply() error {
+	if at.f == nil {
+		return nil
+	}
+	at.cur = at.modifyTermios(at.orig)
+	return at.setAttr(at.cur)
+}
+
 func (at *Attr) modifyTermios(attr syscall.Termios) syscall.Termios {
+	if at.raw || at.cbreak {
+		// non-canonical input, one byte at a time
+		attr.Iflag &^= syscall.ICRNL
+		attr.Lflag &^= syscall.ICANON
+		attr.Cc[syscall.VMIN] = 1
+		attr.Cc[syscall.VTIME] = 0
+		if at.timing {
+			attr.Cc[syscall.VMIN] = at.vmin
+			attr.Cc[syscall.VTIME] = at.vtime
+		}
+	}
 	if at.raw {
-		// TODO read things like antirez's kilo notes again
-
-		// TODO naturalize / decompose
-		attr.Iflag &^= syscall.BRKINT | syscall.ICRNL | syscall.INPCK | syscall.ISTRIP | syscall.IXON
+		// no signals, literal next, or other input processing; 8-bit clean
+		attr.Iflag &^= syscall.BRKINT | syscall.INPCK | syscall.ISTRIP | syscall.IXON
 		attr.Oflag &^= syscall.OPOST
 		attr.Cflag &^= syscall.CSIZE | syscall.PARENB
 		attr.Cflag |= syscall.CS8
-		attr.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
-		attr.Cc[syscall.VMIN] = 1
-		attr.Cc[syscall.VTIME] = 0
-
+		attr.Lflag &^= syscall.IEXTEN | syscall.ISIG
+	}
+	switch at.post {
+	case flagOn:
+		attr.Oflag |= syscall.OPOST
+	case flagOff:
+		attr.Oflag &^= syscall.OPOST
+	}
+	switch at.flow {
+	case flagOn:
+		attr.Iflag |= syscall.IXON
+	case flagOff:
+		attr.Iflag &^= syscall.IXON | syscall.IXOFF
 	}
 	if at.echo {
 		attr.Lflag |= syscall.ECHO
@@ -122,10 +263,14 @@ func (at *Attr) ioctl(request, arg1, arg2, arg3, arg4 uintptr) error {
 }
 
 func (at *Attr) getAttr() (attr syscall.Termios, err error) {
-	err = at.ioctl(syscall.TIOCGETD, uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
+	err = at.ioctl(ioctlGetAttr, uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
 	return
 }
 
 func (at *Attr) setAttr(attr syscall.Termios) error {
-	return at.ioctl(syscall.TIOCSETD, uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
+	req := uintptr(ioctlSetAttr)
+	if at.Drain {
+		req = ioctlSetAttrDrain
+	}
+	return at.ioctl(req, uintptr(unsafe.Pointer(&attr)), 0, 0, 0)
 }
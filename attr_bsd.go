@@ -0,0 +1,13 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package anansi
+
+import "syscall"
+
+// termios ioctl requests
+const (
+	ioctlGetAttr      = syscall.TIOCGETA
+	ioctlSetAttr      = syscall.TIOCSETA  // TCSANOW
+	ioctlSetAttrDrain = syscall.TIOCSETAW // TCSADRAIN
+)
@@ -0,0 +1,14 @@
+//go:build linux
+// +build linux
+
+package anansi
+
+import "syscall"
+
+// termios ioctl requests; syscall lacks TCSETSW, which directly follows
+// TCSETS on every linux architecture.
+const (
+	ioctlGetAttr      = syscall.TCGETS
+	ioctlSetAttr      = syscall.TCSETS     // TCSANOW
+	ioctlSetAttrDrain = syscall.TCSETS + 1 // TCSADRAIN
+)
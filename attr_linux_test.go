@@ -0,0 +1,123 @@
+package anansi_test
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/pty"
+)
+
+func ioctl(f *os.File, req, arg uintptr) error {
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), req, arg); e != 0 {
+		return e
+	}
+	return nil
+}
+
+func getTermios(t *testing.T, f *os.File) (attr syscall.Termios) {
+	require.NoError(t, ioctl(f, syscall.TCGETS, uintptr(unsafe.Pointer(&attr))))
+	return attr
+}
+
+func TestAttr_termios(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		setup func(at *Attr) error
+		check func(t *testing.T, attr syscall.Termios)
+	}{
+		{
+			name:  "raw",
+			setup: func(at *Attr) error { return at.SetRaw(true) },
+			check: func(t *testing.T, attr syscall.Termios) {
+				assert.Zero(t, attr.Lflag&syscall.ICANON, "expected ICANON cleared")
+				assert.Zero(t, attr.Lflag&syscall.ISIG, "expected ISIG cleared")
+				assert.Zero(t, attr.Lflag&syscall.ECHO, "expected ECHO cleared")
+				assert.Zero(t, attr.Oflag&syscall.OPOST, "expected OPOST cleared")
+				assert.Zero(t, attr.Iflag&syscall.IXON, "expected IXON cleared")
+				assert.Equal(t, uint8(1), attr.Cc[syscall.VMIN], "expected VMIN")
+				assert.Equal(t, uint8(0), attr.Cc[syscall.VTIME], "expected VTIME")
+			},
+		},
+
+		{
+			name:  "cbreak",
+			setup: func(at *Attr) error { return at.SetCBreak(true) },
+			check: func(t *testing.T, attr syscall.Termios) {
+				assert.Zero(t, attr.Lflag&syscall.ICANON, "expected ICANON cleared")
+				assert.NotZero(t, attr.Lflag&syscall.ISIG, "expected ISIG kept")
+				assert.NotZero(t, attr.Oflag&syscall.OPOST, "expected OPOST kept")
+			},
+		},
+
+		{
+			name: "raw with output processing and flow control",
+			setup: func(at *Attr) error {
+				if err := at.SetRaw(true); err != nil {
+					return err
+				}
+				if err := at.SetOutputProcessing(true); err != nil {
+					return err
+				}
+				return at.SetFlowControl(true)
+			},
+			check: func(t *testing.T, attr syscall.Termios) {
+				assert.Zero(t, attr.Lflag&syscall.ISIG, "expected ISIG cleared")
+				assert.NotZero(t, attr.Oflag&syscall.OPOST, "expected OPOST set")
+				assert.NotZero(t, attr.Iflag&syscall.IXON, "expected IXON set")
+			},
+		},
+
+		{
+			name: "read min",
+			setup: func(at *Attr) error {
+				if err := at.SetRaw(true); err != nil {
+					return err
+				}
+				return at.SetReadMin(0, 300*time.Millisecond)
+			},
+			check: func(t *testing.T, attr syscall.Termios) {
+				assert.Equal(t, uint8(0), attr.Cc[syscall.VMIN], "expected VMIN")
+				assert.Equal(t, uint8(3), attr.Cc[syscall.VTIME], "expected VTIME")
+			},
+		},
+	} {
+		for _, entered := range []bool{false, true} {
+			name := tc.name
+			if entered {
+				name += " after enter"
+			}
+			t.Run(name, logBuf.With(func(t *testing.T) {
+				pt, err := pty.Open()
+				if err != nil {
+					t.Skipf("unable to open pty: %v", err)
+				}
+				defer pt.Close()
+				slave := pt.Slave
+				orig := getTermios(t, slave)
+
+				term := NewTerm(slave)
+				term.Drain = entered
+				if !entered {
+					require.NoError(t, tc.setup(&term.Attr))
+				}
+				require.NoError(t, term.Attr.Enter(term))
+				if entered {
+					require.NoError(t, tc.setup(&term.Attr))
+				}
+				attr := getTermios(t, slave)
+				tc.check(t, attr)
+				assert.Equal(t, attr.Oflag&syscall.OPOST != 0, term.OutputProcessing(), "expected output processing reported")
+
+				require.NoError(t, term.Attr.Exit(term))
+				assert.Equal(t, orig, getTermios(t, slave), "expected original termios restored")
+			}))
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package anansi
+
+import (
+	"image"
+
+	"github.com/jcorbin/anansi/ansi"
+)
+
+// Bitmap is a 2-color bitmap, with one bool per pixel, targeting unicode
+// braille runes: each screen cell covers a 2x4 block of pixels, doubling
+// horizontal and quadrupling vertical resolution. Like an image.Alpha, pixels
+// are indexed relative to Rect.Min, with Stride pixels per row.
+type Bitmap struct {
+	Bit    []bool
+	Stride int
+	Rect   image.Rectangle
+}
+
+// BitmapStyle determines the foreground attribute of a braille cell, given its
+// 0-indexed offset in the bitmap's rune grid (see Bitmap.RuneSize), e.g. to
+// color each cell of a chart by value; a zero attribute leaves the
+// foreground unchanged.
+type BitmapStyle func(cell image.Point) ansi.SGRAttr
+
+// NewBitmap creates a new, all clear, bitmap with the given bounds.
+func NewBitmap(r image.Rectangle) *Bitmap {
+	bi := &Bitmap{}
+	bi.Resize(r)
+	return bi
+}
+
+// Resize the bitmap to the given bounds, clearing all of its pixels.
+func (bi *Bitmap) Resize(r image.Rectangle) {
+	w, h := r.Dx(), r.Dy()
+	if w < 0 || h < 0 {
+		w, h = 0, 0
+	}
+	n := w * h
+	if n > cap(bi.Bit) {
+		bi.Bit = make([]bool, n)
+	} else {
+		bi.Bit = bi.Bit[:n]
+		bi.Clear()
+	}
+	bi.Stride = w
+	bi.Rect = r
+}
+
+// Bounds returns the bitmap's bounding rectangle.
+func (bi *Bitmap) Bounds() image.Rectangle { return bi.Rect }
+
+// RuneSize returns the size of the bitmap in braille runes (screen cells).
+func (bi *Bitmap) RuneSize() image.Point {
+	sz := bi.Rect.Size()
+	return image.Pt((sz.X+1)/2, (sz.Y+3)/4)
+}
+
+// PixOffset returns the index of the Bit element corresponding to the pixel
+// at p.
+func (bi *Bitmap) PixOffset(p image.Point) int {
+	return (p.Y-bi.Rect.Min.Y)*bi.Stride + (p.X - bi.Rect.Min.X)
+}
+
+// At returns true if the pixel at p is set; pixels outside of Rect are clear.
+func (bi *Bitmap) At(p image.Point) bool {
+	if !p.In(bi.Rect) {
+		return false
+	}
+	return bi.Bit[bi.PixOffset(p)]
+}
+
+// Set the pixel at p, ignoring points outside of Rect.
+func (bi *Bitmap) Set(p image.Point, b bool) {
+	if p.In(bi.Rect) {
+		bi.Bit[bi.PixOffset(p)] = b
+	}
+}
+
+// Clear all pixels.
+func (bi *Bitmap) Clear() {
+	for i := range bi.Bit {
+		bi.Bit[i] = false
+	}
+}
+
+// Line sets all pixels along the line from p0 to p1, inclusive, to b.
+func (bi *Bitmap) Line(p0, p1 image.Point, b bool) {
+	d := p1.Sub(p0)
+	sx, sy := 1, 1
+	if d.X < 0 {
+		d.X, sx = -d.X, -1
+	}
+	if d.Y < 0 {
+		d.Y, sy = -d.Y, -1
+	}
+	// Bresenham, generalized to all octants
+	err := d.X - d.Y
+	for p := p0; ; {
+		bi.Set(p, b)
+		if p == p1 {
+			return
+		}
+		e2 := 2 * err
+		if e2 > -d.Y {
+			err -= d.Y
+			p.X += sx
+		}
+		if e2 < d.X {
+			err += d.X
+			p.Y += sy
+		}
+	}
+}
+
+// Rectangle sets all pixels along the border of r to b, or all pixels within
+// r if fill is true.
+func (bi *Bitmap) Rectangle(r image.Rectangle, b, fill bool) {
+	r = r.Canon()
+	if r.Empty() {
+		return
+	}
+	if fill {
+		r = r.Intersect(bi.Rect)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				bi.Bit[bi.PixOffset(image.Pt(x, y))] = b
+			}
+		}
+		return
+	}
+	max := r.Max.Sub(image.Pt(1, 1))
+	bi.Line(r.Min, image.Pt(max.X, r.Min.Y), b)
+	bi.Line(image.Pt(max.X, r.Min.Y), max, b)
+	bi.Line(max, image.Pt(r.Min.X, max.Y), b)
+	bi.Line(image.Pt(r.Min.X, max.Y), r.Min, b)
+}
+
+// Circle sets all pixels along the circle of the given radius around c to b.
+func (bi *Bitmap) Circle(c image.Point, radius int, b bool) {
+	// midpoint circle, plotting all 8 octants of each step
+	for x, y, err := radius, 0, 1-radius; x >= y; y++ {
+		for _, d := range [8]image.Point{
+			{x, y}, {y, x}, {-y, x}, {-x, y},
+			{-x, -y}, {-y, -x}, {y, -x}, {x, -y},
+		} {
+			bi.Set(c.Add(d), b)
+		}
+		if err < 0 {
+			err += 2*y + 3
+		} else {
+			x--
+			err += 2*(y-x) + 3
+		}
+	}
+}
+
+// Plot draws a line chart of the given values, one per column starting from
+// Rect.Min.X, each scaled so that min maps to the bottom row and max to the
+// top row; consecutive points are connected by lines.
+func (bi *Bitmap) Plot(values []float64, min, max float64) {
+	h := bi.Rect.Dy() - 1
+	if h < 0 || max <= min {
+		return
+	}
+	var last image.Point
+	for i, v := range values {
+		if v < min {
+			v = min
+		} else if v > max {
+			v = max
+		}
+		p := image.Pt(bi.Rect.Min.X+i, bi.Rect.Max.Y-1-int((v-min)/(max-min)*float64(h)+0.5))
+		if i == 0 {
+			bi.Set(p, true)
+		} else {
+			bi.Line(last, p, true)
+		}
+		last = p
+	}
+}
+
+// braille dot bits, indexed by [y][x] pixel offset within a cell
+var brailleBits = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// Rune returns the braille rune for the given 0-indexed cell in the bitmap's
+// rune grid, or 0 if none of its pixels are set.
+func (bi *Bitmap) Rune(cell image.Point) (r rune) {
+	p0 := bi.Rect.Min.Add(image.Pt(cell.X*2, cell.Y*4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 2; x++ {
+			if bi.At(p0.Add(image.Pt(x, y))) {
+				r |= brailleBits[y][x]
+			}
+		}
+	}
+	if r != 0 {
+		r |= 0x2800
+	}
+	return r
+}
+
+// DrawBitmap draws the bitmap's braille runes into the grid, with the bitmap's
+// first cell at the given screen point, clipping to the grid's bounds. Cells
+// with no pixels set are left untouched, so that the bitmap may be composed
+// over existing content. Drawn cells retain their existing background (and
+// other non-foreground) attributes, taking their foreground from style if
+// it's not nil.
+func DrawBitmap(g Grid, at ansi.Point, bi *Bitmap, style BitmapStyle) {
+	sz := bi.RuneSize()
+	for y := 0; y < sz.Y; y++ {
+		for x := 0; x < sz.X; x++ {
+			cell := image.Pt(x, y)
+			r := bi.Rune(cell)
+			if r == 0 {
+				continue
+			}
+			pt := at
+			pt.Point = pt.Point.Add(cell)
+			i, ok := g.CellOffset(pt)
+			if !ok {
+				continue
+			}
+			attr := g.Attr[i]
+			if style != nil {
+				if fg := style(cell); fg != 0 {
+					attr = attr.SansFG() | fg&ansi.SGRAttrFGMask
+				}
+			}
+			g.Rune[i], g.Attr[i] = r, attr
+			g.markDirty(pt.Y-1, pt.Y)
+		}
+	}
+}
+
+// RenderBitmap writes the bitmap's braille runes into the given buffer as
+// lines of text, separated by newlines, with cells that have no pixels set
+// written as spaces. Foreground attributes from style, if it's not nil, are
+// written as needed, and cleared after the last line. Returns the number of
+// bytes written.
+func RenderBitmap(buf *ansi.Buffer, bi *Bitmap, style BitmapStyle) (n int) {
+	var cur ansi.SGRAttr
+	sz := bi.RuneSize()
+	for y := 0; y < sz.Y; y++ {
+		if y > 0 {
+			m, _ := buf.WriteRune('\n')
+			n += m
+		}
+		for x := 0; x < sz.X; x++ {
+			cell := image.Pt(x, y)
+			r := bi.Rune(cell)
+			if r == 0 {
+				r = ' '
+			} else if style != nil {
+				if fg := style(cell) & ansi.SGRAttrFGMask; fg != 0 && fg != cur {
+					n += buf.WriteSGR(fg)
+					cur = fg
+				}
+			}
+			m, _ := buf.WriteRune(r)
+			n += m
+		}
+	}
+	if cur != 0 {
+		n += buf.WriteSGR(ansi.SGRAttrClear)
+	}
+	return n
+}
@@ -0,0 +1,115 @@
+package anansi_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/ansi"
+	anansitest "github.com/jcorbin/anansi/test"
+)
+
+func TestBitmap(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		size  image.Point
+		draw  func(bi *Bitmap)
+		runes string
+	}{
+		{
+			name: "dots",
+			size: image.Pt(4, 4),
+			draw: func(bi *Bitmap) {
+				bi.Set(image.Pt(0, 0), true)
+				bi.Set(image.Pt(3, 3), true)
+			},
+			runes: "⠁⢀",
+		},
+
+		{
+			name: "line",
+			size: image.Pt(4, 8),
+			draw: func(bi *Bitmap) {
+				bi.Line(image.Pt(0, 0), image.Pt(3, 7), true)
+			},
+			runes: "⢣ \n ⢣",
+		},
+
+		{
+			name: "rectangle",
+			size: image.Pt(4, 4),
+			draw: func(bi *Bitmap) {
+				bi.Rectangle(bi.Rect, true, false)
+			},
+			runes: "⣏⣹",
+		},
+
+		{
+			name: "filled rectangle",
+			size: image.Pt(4, 4),
+			draw: func(bi *Bitmap) {
+				bi.Rectangle(image.Rect(1, 0, 3, 4), true, true)
+			},
+			runes: "⢸⡇",
+		},
+
+		{
+			name: "circle",
+			size: image.Pt(6, 8),
+			draw: func(bi *Bitmap) {
+				bi.Circle(image.Pt(3, 3), 2, true)
+			},
+			runes: "⢠⠒⢢\n⠈⠒⠊",
+		},
+
+		{
+			name: "plot",
+			size: image.Pt(4, 4),
+			draw: func(bi *Bitmap) {
+				bi.Plot([]float64{0, 1, 2, 3}, 0, 3)
+			},
+			runes: "⡠⠊",
+		},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			bi := NewBitmap(image.Rectangle{Max: tc.size})
+			tc.draw(bi)
+			var buf ansi.Buffer
+			RenderBitmap(&buf, bi, nil)
+			assert.Equal(t, tc.runes, string(buf.Bytes()))
+		}))
+	}
+}
+
+func TestDrawBitmap(t *testing.T) {
+	var g Grid
+	g.Resize(image.Pt(4, 2))
+	for i := range g.Rune {
+		g.Rune[i], g.Attr[i] = '.', ansi.SGRBlue.BG()
+	}
+
+	bi := NewBitmap(image.Rect(0, 0, 4, 4))
+	bi.Line(image.Pt(0, 3), image.Pt(3, 0), true)
+	DrawBitmap(g, ansi.Pt(2, 2), bi, func(cell image.Point) ansi.SGRAttr {
+		if cell.X == 0 {
+			return ansi.SGRRed.FG()
+		}
+		return ansi.SGRGreen.FG()
+	})
+
+	rs, as := anansitest.GridRowData(g)
+	assert.Equal(t, [][]rune{
+		[]rune("...."),
+		[]rune(".⡠⠊."),
+	}, rs)
+	assert.Equal(t, [][]ansi.SGRAttr{
+		{ansi.SGRBlue.BG(), ansi.SGRBlue.BG(), ansi.SGRBlue.BG(), ansi.SGRBlue.BG()},
+		{ansi.SGRBlue.BG(), ansi.SGRBlue.BG() | ansi.SGRRed.FG(), ansi.SGRBlue.BG() | ansi.SGRGreen.FG(), ansi.SGRBlue.BG()},
+	}, as)
+
+	var buf ansi.Buffer
+	RenderBitmap(&buf, bi, func(cell image.Point) ansi.SGRAttr { return ansi.SGRRed.FG() })
+	assert.Equal(t, "\x1b[31m⡠⠊\x1b[0m", string(buf.Bytes()))
+}
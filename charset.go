@@ -0,0 +1,130 @@
+package anansi
+
+import "github.com/jcorbin/anansi/ansi"
+
+// charset identifies a 94-character graphic set by the final byte of its
+// designating SCS sequence; the zero value is ASCII.
+type charset byte
+
+// supported character sets
+const (
+	charsetASCII           charset = 'B'
+	charsetUK              charset = 'A'
+	charsetDECSpecial      charset = '0'
+	charsetDECSupplemental charset = '<'
+)
+
+// decSpecialGraphics maps 0x5F-0x7E from the DEC Special Graphics set.
+var decSpecialGraphics = [...]rune{
+	' ',                                    // _ blank
+	'◆', '▒', '␉', '␌', '␍', '␊', '°', '±', // ` a b c d e f g
+	'␤', '␋', '┘', '┐', '┌', '└', '┼', '⎺', // h i j k l m n o
+	'⎻', '─', '⎼', '⎽', '├', '┤', '┴', '┬', // p q r s t u v w
+	'│', '≤', '≥', 'π', '≠', '£', '·', // x y z { | } ~
+}
+
+// translate maps an ASCII graphic rune into the character set.
+func (cs charset) translate(r rune) rune {
+	if r <= 0x20 || r >= 0x7F {
+		return r
+	}
+	switch cs {
+	case charsetUK:
+		if r == '#' {
+			return '£'
+		}
+	case charsetDECSpecial:
+		if r >= 0x5F {
+			return decSpecialGraphics[r-0x5F]
+		}
+	case charsetDECSupplemental:
+		// mostly the upper half of Latin-1
+		switch r {
+		case '(':
+			return '¤'
+		case 'W':
+			return 'Œ'
+		case ']':
+			return 'Ÿ'
+		case 'w':
+			return 'œ'
+		case '}':
+			return 'ÿ'
+		}
+		return r + 0x80
+	}
+	return r
+}
+
+// charsets tracks G0-G3 character set designations, and which of them is
+// invoked into GL (either by locking shift, or by a single shift).
+type charsets struct {
+	g      [4]charset
+	gl     int // locking shift
+	single int // single shift, if non-zero
+}
+
+// translate maps a graphic rune through the active character set, consuming
+// any single shift.
+func (css *charsets) translate(r rune) rune {
+	i := css.gl
+	if css.single != 0 {
+		i, css.single = css.single, 0
+	}
+	return css.g[i].translate(r)
+}
+
+// designate processes an SCS escape sequence, returning true if it was one.
+func (css *charsets) designate(e ansi.Escape, a []byte) bool {
+	var i int
+	switch e {
+	case ansi.ESC('('):
+		i = 0
+	case ansi.ESC(')'), ansi.ESC('-'):
+		i = 1
+	case ansi.ESC('*'), ansi.ESC('.'):
+		i = 2
+	case ansi.ESC('+'), ansi.ESC('/'):
+		i = 3
+	case ansi.ESC('5'):
+		// 2 intermediate bytes like ESC ( % 5, designating DEC Supplemental
+		if len(a) == 2 && a[1] == '%' {
+			switch a[0] {
+			case '(', ')', '*', '+':
+				css.g[a[0]-'('] = charsetDECSupplemental
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+	if len(a) != 1 {
+		return true
+	}
+	switch cs := charset(a[0]); cs {
+	case charsetUK, charsetDECSpecial, charsetDECSupplemental:
+		css.g[i] = cs
+	default:
+		css.g[i] = charsetASCII
+	}
+	return true
+}
+
+// shift processes locking and single shift controls, returning true if the
+// rune was one.
+func (css *charsets) shift(r rune) bool {
+	switch r {
+	case 0x0E: // SO
+		css.gl = 1
+	case 0x0F: // SI
+		css.gl = 0
+	case 0x8E: // SS2
+		css.single = 2
+	case 0x8F: // SS3
+		css.single = 3
+	default:
+		return false
+	}
+	return true
+}
@@ -101,10 +101,7 @@ func (d *demo) Update(ctx *platform.Context) (err error) {
 		if ch == 0 {
 			ch = def
 		}
-		if j, ok := ctx.Output.CellOffset(p); ok {
-			ctx.Output.Grid.Rune[j] = ch
-			ctx.Output.Grid.Attr[j] = d.Grid.Attr[i]
-		}
+		ctx.Output.Grid.Set(p, ch, d.Grid.Attr[i])
 		if p.X++; p.X >= r.Max.X {
 			p.X = r.Min.X
 			p.Y++
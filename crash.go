@@ -0,0 +1,111 @@
+package anansi
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime/debug"
+
+	"github.com/jcorbin/anansi/ansi"
+)
+
+// Crash is a Context that causes Term.With to recover any panic from within
+// it: all terminal context is exited (restoring modes, SGR, cursor, and
+// termios state), and then the panic and its stack are printed to the normal
+// screen, rather than being lost to the alternate screen. An optional crash
+// dump is then written, and Term.With returns a *Panic error.
+type Crash struct {
+	// Report is where the panic and its stack are printed; defaults to the
+	// terminal file.
+	Report io.Writer
+
+	// DumpFile, if not empty, names a file to write a crash dump into, as
+	// provided by the Dumpers; e.g. the last recorded input and serialized
+	// application state, for later replay.
+	DumpFile string
+	Dumpers  []CrashDumper
+}
+
+// CrashDumper provides data for a crash dump.
+type CrashDumper interface {
+	CrashDump(w io.Writer) error
+}
+
+// Panic is the error returned by Term.With after recovering a panic under a
+// Crash context.
+type Panic struct {
+	Value interface{} // the recovered value
+	Stack []byte      // the stack of the panicking goroutine
+	Dump  string      // name of any crash dump file written
+}
+
+func (p *Panic) Error() string { return fmt.Sprintf("panic: %v", p.Value) }
+
+// Enter does nothing; Crash only needs to be present in a Term's context.
+func (crash *Crash) Enter(term *Term) error { return nil }
+
+// Exit does nothing; Crash only needs to be present in a Term's context.
+func (crash *Crash) Exit(term *Term) error { return nil }
+
+// recovered is called by Term.With after recovering a panic, but before
+// exiting terminal context: any partially written control sequence is
+// cancelled, and synchronized output, SGR, and cursor state are reset.
+func (crash *Crash) recovered(term *Term, e interface{}) *Panic {
+	pnc := &Panic{Value: e, Stack: debug.Stack()}
+	buf := []byte{0x18} // CAN
+	buf = ansi.ModeSyncOutput.Reset().AppendTo(buf)
+	buf = ansi.SGRReset.AppendTo(buf)
+	buf = ansi.ShowCursor.Set().AppendTo(buf)
+	_, _ = term.File.Write(buf)
+	return pnc
+}
+
+// report prints the panic and its stack, after terminal context has been
+// exited, and then writes any crash dump.
+func (crash *Crash) report(term *Term, pnc *Panic) {
+	w := crash.Report
+	if w == nil {
+		w = term.File
+	}
+	_, _ = fmt.Fprintf(w, "\r\n%v\n\n%s\n", pnc, pnc.Stack)
+	if crash.DumpFile == "" || len(crash.Dumpers) == 0 {
+		return
+	}
+	if err := crash.dump(); err != nil {
+		_, _ = fmt.Fprintf(w, "failed to write crash dump to %q: %v\n", crash.DumpFile, err)
+		return
+	}
+	pnc.Dump = crash.DumpFile
+	_, _ = fmt.Fprintf(w, "crash dump written to %q\n", crash.DumpFile)
+}
+
+func (crash *Crash) dump() error {
+	f, err := os.Create(crash.DumpFile)
+	if err != nil {
+		return err
+	}
+	for _, dumper := range crash.Dumpers {
+		if err = dumper.CrashDump(f); err != nil {
+			break
+		}
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// findCrash returns any Crash within the given context.
+func findCrash(ctx Context) *Crash {
+	switch impl := ctx.(type) {
+	case *Crash:
+		return impl
+	case contexts:
+		for _, c := range impl {
+			if crash := findCrash(c); crash != nil {
+				return crash
+			}
+		}
+	}
+	return nil
+}
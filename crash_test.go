@@ -0,0 +1,85 @@
+package anansi_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/ansi"
+	"github.com/jcorbin/anansi/pty"
+)
+
+type crashDumpFunc func(w io.Writer) error
+
+func (f crashDumpFunc) CrashDump(w io.Writer) error { return f(w) }
+
+func TestCrash(t *testing.T) {
+	pt, err := pty.Open()
+	if err != nil {
+		t.Skipf("unable to open pty: %v", err)
+	}
+	defer pt.Close()
+
+	dir, err := ioutil.TempDir("", "anansi-crash")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var report bytes.Buffer
+	crash := Crash{
+		Report:   &report,
+		DumpFile: filepath.Join(dir, "crash.rec"),
+		Dumpers: []CrashDumper{crashDumpFunc(func(w io.Writer) error {
+			_, err := io.WriteString(w, "last input")
+			return err
+		})},
+	}
+	term := NewTerm(pt.Slave, &crash, Modes(ansi.ModeAlternateScreen))
+
+	err = term.With(func(term *Term) error {
+		_, _ = term.WriteString("\x1b[1;3") // partial frame
+		panic("boom")
+	})
+	require.IsType(t, &Panic{}, err, "expected a panic error")
+	pnc := err.(*Panic)
+	assert.Equal(t, "boom", pnc.Value)
+	assert.Equal(t, "panic: boom", pnc.Error())
+	assert.Contains(t, string(pnc.Stack), "TestCrash", "expected stack to include test function")
+	assert.Equal(t, crash.DumpFile, pnc.Dump)
+
+	assert.Contains(t, report.String(), "panic: boom\n")
+	assert.Contains(t, report.String(), "crash dump written to")
+	dump, err := ioutil.ReadFile(crash.DumpFile)
+	require.NoError(t, err)
+	assert.Equal(t, "last input", string(dump))
+
+	// the partial frame is cancelled before exiting the alternate screen
+	out := readAvailable(t, pt.Master)
+	exitAlt := ansi.ModeAlternateScreen.Reset().AppendTo(nil)
+	cancel := bytes.IndexByte(out, 0x18)
+	assert.True(t, cancel > bytes.Index(out, []byte("\x1b[1;3")), "expected CAN after partial frame in %q", out)
+	assert.True(t, cancel < bytes.Index(out, exitAlt), "expected CAN before alternate screen exit in %q", out)
+}
+
+// readAvailable reads from f until no more input arrives for 100ms; the
+// returned bytes are interspersed with Input recording time marks.
+func readAvailable(t *testing.T, f *os.File) []byte {
+	in := NewInput(f, 0)
+	var out bytes.Buffer
+	in.SetRecording(&out)
+	for {
+		n, err := in.ReadTimeout(100 * time.Millisecond)
+		require.NoError(t, err)
+		if n == 0 {
+			break
+		}
+	}
+	return out.Bytes()
+}
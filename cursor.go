@@ -11,9 +11,44 @@ type Cursor struct {
 	CursorState
 	Real CursorState
 
+	// Tabs are the horizontal tab stops, as set by HTS and cleared by TBC
+	// written through the cursor.
+	Tabs TabStops
+
 	buf ansi.Buffer
 }
 
+// ProcessRune updates cursor state like CursorState.ProcessRune, except that
+// HT moves to the next of Tabs, and HTS sets one.
+func (c *Cursor) ProcessRune(r rune) {
+	switch r {
+	case '\x09': // HT
+		c.wrapNext = false
+		c.X = c.Tabs.Next(c.X, 0)
+	case 0x88: // HTS
+		c.Tabs.Set(c.X)
+	default:
+		c.CursorState.ProcessRune(r)
+	}
+}
+
+// ProcessEscape updates cursor state like CursorState.ProcessEscape, except
+// that CHT and CBT move by Tabs, and TBC clears them.
+func (c *Cursor) ProcessEscape(e ansi.Escape, a []byte) {
+	switch e {
+	case ansi.CHT, ansi.CBT: // tab forward / backward
+		c.wrapNext = false
+		if n, ok := decodeCount(a); ok {
+			c.X = c.Tabs.tab(e == ansi.CHT, c.X, n, 0)
+		}
+	case ansi.TBC:
+		c.wrapNext = false
+		c.Tabs.processTBC(c.X, a)
+	default:
+		c.CursorState.ProcessEscape(e, a)
+	}
+}
+
 // Reset the internal buffer and restore cursor state to last state affected by
 // WriteTo.
 func (c *Cursor) Reset() {
@@ -117,6 +152,12 @@ func (c *Cursor) Hide() {
 	c.buf.Skip(c.buf.WriteSeq(c.CursorState.Hide()))
 }
 
+// Style ensures that the cursor has the given shape and blink state, writing
+// the necessary control sequence into the internal buffer if this is a change.
+func (c *Cursor) Style(shape ansi.CursorShape, blink bool) {
+	c.buf.Skip(c.buf.WriteSeq(c.CursorState.Style(shape, blink)))
+}
+
 // Apply the given cursor state, writing any necessary escape sequences into
 // the internal buffer.
 func (c *Cursor) Apply(cs CursorState) {
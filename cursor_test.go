@@ -3,6 +3,8 @@ package anansi_test
 import (
 	"bufio"
 	"bytes"
+	"image"
+	"io/ioutil"
 	"log"
 	"testing"
 
@@ -50,6 +52,112 @@ func TestCursor(t *testing.T) {
 	}
 }
 
+func TestCursorState_tabs(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		writes string
+		x      int
+	}{
+		{"default", "ab\t", 9},
+		{"default twice", "\t\t", 17},
+		{"at stop", "\x1b[9G\t", 17},
+		{"set", "\x1b[4G\x1bH\r\t", 4},
+		{"clear", "\x1b[9G\x1b[g\r\t", 17},
+		{"clear all", "\x1b[4G\x1bH\x1b[3g\r\t", 1},
+		{"forward", "\x1b[3I", 25},
+		{"backward", "\x1b[20G\x1b[2Z", 9},
+		{"backward past start", "\x1b[5G\x1b[2Z", 1},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			var cur Cursor
+			cur.To(ansi.Pt(1, 1))
+			cur.WriteString(tc.writes)
+			assert.Equal(t, tc.x, cur.X, "expected cursor column")
+
+			// tab stops aren't cursor state, which remains comparable
+			_, err := cur.WriteTo(ioutil.Discard)
+			require.NoError(t, err)
+			assert.True(t, cur.Real == cur.CursorState, "expected real cursor state")
+		}))
+	}
+}
+
+func TestCursorState_MoveTo(t *testing.T) {
+	var known Grid
+	known.Resize(image.Pt(20, 4))
+	for i, r := range "hello world" {
+		known.Rune[i] = r
+	}
+	for i := known.Size.X; i < len(known.Attr); i++ {
+		known.Rune[i], known.Attr[i] = '.', ansi.SGRAttrBold
+	}
+
+	for _, tc := range []struct {
+		name   string
+		from   ansi.Point
+		to     ansi.Point
+		expect string
+	}{
+		{"unknown", ansi.ZP, ansi.Pt(3, 2), "\x1b[2;3H"},
+		{"home", ansi.ZP, ansi.Pt(1, 1), "\x1b[H"},
+		{"noop", ansi.Pt(3, 2), ansi.Pt(3, 2), ""},
+		{"carriage return", ansi.Pt(9, 2), ansi.Pt(1, 2), "\r"},
+		{"newline", ansi.Pt(9, 2), ansi.Pt(1, 3), "\r\n"},
+		{"line feeds", ansi.Pt(9, 1), ansi.Pt(9, 3), "\n\n"},
+		{"backspace", ansi.Pt(9, 2), ansi.Pt(7, 2), "\b\b"},
+		{"back", ansi.Pt(19, 2), ansi.Pt(10, 2), "\x1b[9D"},
+		{"return tab", ansi.Pt(19, 2), ansi.Pt(9, 2), "\r\t"},
+		{"tab", ansi.Pt(1, 2), ansi.Pt(17, 2), "\t\t"},
+		{"rewrite", ansi.Pt(1, 1), ansi.Pt(4, 1), "hel"},
+		{"return rewrite", ansi.Pt(18, 1), ansi.Pt(3, 1), "\rhe"},
+		{"up", ansi.Pt(5, 4), ansi.Pt(5, 2), "\x1b[2A"},
+		{"row", ansi.Pt(5, 4), ansi.Pt(5, 1), "\x1b[d"},
+		{"absolute", ansi.Pt(20, 4), ansi.Pt(12, 1), "\x1b[1;12H"},
+		{"pending wrap", ansi.Pt(21, 2), ansi.Pt(19, 2), "\x1b[19G"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf ansi.Buffer
+			var cs CursorState
+			cs.MergeSGR(0)
+			cs.Point = tc.from
+			n := cs.MoveTo(tc.to, &buf, known, TabStops{})
+			assert.Equal(t, tc.expect, string(buf.Bytes()), "expected output")
+			assert.Equal(t, len(tc.expect), n, "expected byte count")
+			assert.Equal(t, tc.to, cs.Point, "expected cursor point")
+		})
+	}
+}
+
+func TestCursorState_MoveTo_tabs(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		tabs   string
+		from   ansi.Point
+		to     ansi.Point
+		expect string
+	}{
+		{"set", "\x1b[5G\x1bH", ansi.Pt(1, 2), ansi.Pt(5, 2), "\t"},
+		{"set twice", "\x1b[3g\x1b[4G\x1bH\x1b[7G\x1bH", ansi.Pt(1, 2), ansi.Pt(7, 2), "\t\t"},
+		{"clear", "\x1b[9G\x1b[g", ansi.Pt(1, 2), ansi.Pt(17, 2), "\t"},
+		{"clear all", "\x1b[3g", ansi.Pt(1, 2), ansi.Pt(17, 2), "\x1b[16C"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var cur Cursor
+			cur.To(ansi.Pt(1, 1))
+			cur.WriteString(tc.tabs)
+
+			var buf ansi.Buffer
+			var cs CursorState
+			cs.MergeSGR(0)
+			cs.Point = tc.from
+			n := cs.MoveTo(tc.to, &buf, Grid{}, cur.Tabs)
+			assert.Equal(t, tc.expect, string(buf.Bytes()), "expected output")
+			assert.Equal(t, len(tc.expect), n, "expected byte count")
+			assert.Equal(t, tc.to, cs.Point, "expected cursor point")
+		})
+	}
+}
+
 type _logBuf struct {
 	buf bytes.Buffer
 	t   *testing.T
@@ -14,6 +14,15 @@ type Grid struct {
 	Attr []ansi.SGRAttr
 	Rune []rune
 	// TODO []string for multi-rune glyphs
+
+	// Dirty, if non-nil, flags rows that may have changed since the last
+	// Update; unflagged rows are then skipped when diffing. It is nil unless
+	// enabled by TrackDirty, in which case any direct writes to Attr or Rune
+	// must also set the corresponding Dirty flag (see Set).
+	Dirty []bool
+
+	// per-row soft-wrap flags, allocated on first use; see Wrapped
+	wrapped []bool
 }
 
 // Resize the grid to have room for n cells.
@@ -32,9 +41,83 @@ func (g *Grid) Resize(size image.Point) bool {
 	g.Attr = g.Attr[:n]
 	g.Rune = g.Rune[:n]
 	g.Size = size
+	if g.Dirty != nil {
+		g.TrackDirty(true)
+	}
+	if g.wrapped != nil {
+		g.wrapped = g.wrapped[:0]
+		g.setWrapped(0, false)
+	}
 	return true
 }
 
+// Wrapped returns true if the given 1-indexed row was automatically wrapped
+// onto the next row, i.e. if the two rows form one logical line.
+func (g Grid) Wrapped(y int) bool {
+	return 1 <= y && y <= len(g.wrapped) && g.wrapped[y-1]
+}
+
+// setWrapped sets the soft-wrap flag of the given 0-indexed row.
+func (g *Grid) setWrapped(row int, wrapped bool) {
+	if len(g.wrapped) != g.Size.Y {
+		if !wrapped && g.wrapped == nil {
+			return
+		}
+		for g.Size.Y > cap(g.wrapped) {
+			g.wrapped = append(g.wrapped[:cap(g.wrapped)], false)
+		}
+		g.wrapped = g.wrapped[:g.Size.Y]
+		for i := range g.wrapped {
+			g.wrapped[i] = false
+		}
+	}
+	if 0 <= row && row < len(g.wrapped) {
+		g.wrapped[row] = wrapped
+	}
+}
+
+// TrackDirty enables or disables per-row dirty tracking; when enabled, all
+// rows start out dirty.
+func (g *Grid) TrackDirty(enabled bool) {
+	if !enabled {
+		g.Dirty = nil
+		return
+	}
+	for g.Size.Y > cap(g.Dirty) {
+		g.Dirty = append(g.Dirty[:cap(g.Dirty)], true)
+	}
+	g.Dirty = g.Dirty[:g.Size.Y]
+	g.markDirty(0, g.Size.Y)
+}
+
+// Set the rune and attribute of the cell at the given screen point, marking
+// its row dirty. Returns false if the point is outside of the grid.
+func (g Grid) Set(pt ansi.Point, r rune, a ansi.SGRAttr) bool {
+	i, ok := g.CellOffset(pt)
+	if ok {
+		g.Rune[i], g.Attr[i] = r, a
+		g.markDirty(pt.Y-1, pt.Y)
+	}
+	return ok
+}
+
+// markDirty flags the 0-indexed [top, bottom) row range as dirty, if dirty
+// tracking is enabled.
+func (g Grid) markDirty(top, bottom int) {
+	if g.Dirty != nil {
+		for y := top; y < bottom; y++ {
+			g.Dirty[y] = true
+		}
+	}
+}
+
+// clearDirty clears all row dirty flags.
+func (g Grid) clearDirty() {
+	for y := range g.Dirty {
+		g.Dirty[y] = false
+	}
+}
+
 // Bounds returns the screen bounding rectangle of the grid.
 func (g Grid) Bounds() ansi.Rectangle {
 	return ansi.Rect(1, 1, g.Size.X+1, g.Size.Y+1)
@@ -56,16 +139,52 @@ func (g Grid) CellOffset(pt ansi.Point) (int, bool) {
 // redraw is done. Returns the number of bytes written into the buffer, and the
 // final cursor state.
 func (g Grid) Update(cur CursorState, buf *ansi.Buffer, prior Grid) (n int, _ CursorState) {
+	return g.update(cur, buf, prior, g, &shiftFinder{})
+}
+
+// update implements Update, only scrolling shifted rows into place if given a
+// shiftFinder (whose scratch space is reused across updates); cursor movement
+// may only re-write cells of the known grid (see CursorState.MoveTo), and
+// assumes the terminal's default tab stops, since updates never set any.
+func (g Grid) update(cur CursorState, buf *ansi.Buffer, prior, known Grid, sf *shiftFinder) (n int, _ CursorState) {
 	if len(g.Attr) == 0 || len(g.Rune) == 0 {
 		return n, cur
 	}
 	diffing := true
+	dirty := g.Dirty
+	if len(dirty) != g.Size.Y {
+		dirty = nil
+	}
 	if len(prior.Attr) == 0 || len(prior.Rune) == 0 || prior.Size == image.ZP || prior.Size != g.Size {
 		diffing = false
 		n += buf.WriteSeq(ansi.ED.With('2'))
+	} else if sf == nil {
+	} else if dirty != nil && countDirty(dirty) < minScrollGain {
+		// too few dirty rows to be worth looking for a shift
+	} else if top, bottom, k := sf.find(g, prior); k != 0 {
+		// scroll any shifted rows into place on the terminal, and then diff
+		// against a copy of prior that has been shifted similarly
+		m := 0
+		m, cur = scrollRows(cur, buf, g.Size.Y, top, bottom, k)
+		n += m
+		prior = prior.copy()
+		prior.shiftRows(top, bottom, k)
+		if dirty != nil {
+			// clean rows within the scrolled region no longer match prior
+			dirty = append([]bool(nil), dirty...)
+			for y := top; y < bottom; y++ {
+				dirty[y] = true
+			}
+		}
 	}
 
 	for i, pt := 0, ansi.Pt(1, 1); i < len(g.Rune); /* next: */ {
+		if diffing && dirty != nil && pt.X == 1 && !dirty[pt.Y-1] {
+			i += g.Size.X
+			pt.Y++
+			continue
+		}
+
 		gr, ga := g.Rune[i], g.Attr[i]
 
 		if diffing {
@@ -86,13 +205,15 @@ func (g Grid) Update(cur CursorState, buf *ansi.Buffer, prior Grid) (n int, _ Cu
 		}
 
 		if gr != 0 {
-			mv := cur.To(pt)
-			ad := cur.MergeSGR(ga)
-			n += buf.WriteSeq(mv)
-			n += buf.WriteSGR(ad)
-			m, _ := buf.WriteRune(gr)
-			n += m
-			cur.ProcessRune(gr)
+			n += cur.MoveTo(pt, buf, known, TabStops{})
+			n += buf.WriteSGR(cur.MergeSGR(ga))
+			if pt.X < g.Size.X {
+				m, _ := buf.WriteRune(gr)
+				n += m
+				cur.ProcessRune(gr)
+			} else {
+				n += cur.writeLastColumn(buf, gr, pt.Y == g.Size.Y)
+			}
 		}
 
 	next:
@@ -104,3 +225,277 @@ func (g Grid) Update(cur CursorState, buf *ansi.Buffer, prior Grid) (n int, _ Cu
 	}
 	return n, cur
 }
+
+// writeLastColumn writes a rune into the last column, leaving the cursor in
+// it; a wrap is then pending, unless autowrap is disabled. Writing into the
+// final cell of the screen temporarily disables autowrap, since some terminals
+// scroll the screen rather than entering a pending wrap.
+func (cs *CursorState) writeLastColumn(buf *ansi.Buffer, r rune, final bool) (n int) {
+	bracket := final && !cs.autowrapOff
+	if bracket {
+		n += buf.WriteSeq(ansi.ModeAutoWrap.Reset())
+	}
+	m, _ := buf.WriteRune(r)
+	n += m
+	if bracket {
+		n += buf.WriteSeq(ansi.ModeAutoWrap.Set())
+	}
+	cs.wrapNext = !cs.autowrapOff && !final
+	return n
+}
+
+// countDirty returns the number of dirty rows.
+func countDirty(dirty []bool) (n int) {
+	for _, d := range dirty {
+		if d {
+			n++
+		}
+	}
+	return n
+}
+
+// minScrollGain is the minimum number of otherwise changed rows that a
+// vertical shift must save before Update will use a terminal scroll to
+// implement it.
+const minScrollGain = 2
+
+// shiftFinder looks for a block of rows that have been shifted vertically
+// between a prior and a current grid; its scratch space is retained, so that
+// it may be reused across updates without allocating.
+type shiftFinder struct {
+	gh, ph         []uint64 // row hashes
+	gBlank, pBlank []bool   // whether each row is blank
+	changed        []bool   // whether each row differs from the same prior row
+	save, keep     []int    // prefix counts of rows that a shift would save or cost
+}
+
+// find compares per-row hashes to find a shift. Returns the 0-indexed
+// [top, bottom) row range that needs to be scrolled, and the number of rows to
+// scroll it up by (negative for down); k is 0 if no worthwhile shift was
+// found.
+//
+// A shift's gain is the number of non-blank rows that it moves into place,
+// which would otherwise need to be repainted, less the number of unchanged
+// non-blank rows that scrolling exposes, which then need to be repainted.
+// Blank rows are cheap to clear either way, and so don't count.
+func (sf *shiftFinder) find(g, prior Grid) (top, bottom, k int) {
+	h := g.Size.Y
+	if h < 2 || prior.Size != g.Size {
+		return 0, 0, 0
+	}
+	sf.gh, sf.gBlank = g.rowHashes(sf.gh, sf.gBlank)
+	if countNonBlank(sf.gBlank) < minScrollGain {
+		return 0, 0, 0
+	}
+	sf.ph, sf.pBlank = prior.rowHashes(sf.ph, sf.pBlank)
+	if countNonBlank(sf.pBlank) < minScrollGain {
+		return 0, 0, 0
+	}
+	same := func(y, py int) bool {
+		if sf.gh[y] != sf.ph[py] {
+			return false
+		}
+		if sf.gBlank[y] || sf.pBlank[py] {
+			return sf.gBlank[y] && sf.pBlank[py]
+		}
+		return g.rowEq(prior, y, py)
+	}
+
+	sf.changed = sf.changed[:0]
+	sf.save = append(sf.save[:0], 0)
+	sf.keep = append(sf.keep[:0], 0)
+	for y := 0; y < h; y++ {
+		changed := !same(y, y)
+		sf.changed = append(sf.changed, changed)
+		save, keep := sf.save[y], sf.keep[y]
+		if !sf.gBlank[y] {
+			if changed {
+				save++
+			} else {
+				keep++
+			}
+		}
+		sf.save = append(sf.save, save)
+		sf.keep = append(sf.keep, keep)
+	}
+
+	bestGain := minScrollGain - 1
+	for d := 1 - h; d < h; d++ {
+		if d == 0 {
+			continue
+		}
+		// scan for runs of rows y such that g[y] == prior[y+d]
+		y0 := -1
+		for y := 0; y <= h; y++ {
+			if py := y + d; y < h && py >= 0 && py < h && same(y, py) {
+				if y0 < 0 {
+					y0 = y
+				}
+				continue
+			}
+			if y0 < 0 {
+				continue
+			}
+			// rows exposed by scrolling the run's region
+			ex0, ex1 := y, y+d
+			if d < 0 {
+				ex0, ex1 = y0+d, y0
+			}
+			if gain := sf.save[y] - sf.save[y0] - (sf.keep[ex1] - sf.keep[ex0]); gain > bestGain {
+				bestGain, k = gain, d
+				if d > 0 {
+					top, bottom = y0, y+d
+				} else {
+					top, bottom = y0+d, y
+				}
+			}
+			y0 = -1
+		}
+	}
+	return top, bottom, k
+}
+
+// countNonBlank returns the number of rows not flagged as blank.
+func countNonBlank(blank []bool) (n int) {
+	for _, b := range blank {
+		if !b {
+			n++
+		}
+	}
+	return n
+}
+
+// scrollRows writes the control sequences necessary to scroll the given
+// 0-indexed [top, bottom) row range by k rows (up if positive, down if
+// negative). A scrolling region is set, and then reset, around the scroll
+// only if the row range doesn't cover the whole height h. Any SGR attributes
+// are cleared first, since many terminals fill exposed rows with the current
+// background color. Returns the number of bytes written and the updated
+// cursor state.
+func scrollRows(cur CursorState, buf *ansi.Buffer, h, top, bottom, k int) (n int, _ CursorState) {
+	n += buf.WriteSGR(cur.MergeSGR(0))
+	region := top != 0 || bottom != h
+	if region {
+		n += buf.WriteSeq(ansi.DECSTBM.WithInts(top+1, bottom))
+	}
+	id := ansi.SU
+	if k < 0 {
+		id, k = ansi.SD, -k
+	}
+	if k == 1 {
+		n += buf.WriteSeq(id.With())
+	} else {
+		n += buf.WriteSeq(id.WithInts(k))
+	}
+	if region {
+		// NOTE DECSTBM also homes the cursor
+		n += buf.WriteSeq(ansi.DECSTBM.With())
+		cur.Point = ansi.Pt(1, 1)
+	}
+	return n, cur
+}
+
+// rowHashes returns a hash value for each row of cells, and whether each is
+// blank, appending into the given slices (after truncating them); cells are
+// normalized as they are when diffing (a zero rune is the same as a space with
+// zero attributes).
+func (g Grid) rowHashes(hs []uint64, blank []bool) ([]uint64, []bool) {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	hs, blank = hs[:0], blank[:0]
+	for y, i := 0, 0; y < g.Size.Y; y++ {
+		h := uint64(offset64)
+		b := true
+		for x := 0; x < g.Size.X; x, i = x+1, i+1 {
+			r, a := g.Rune[i], g.Attr[i]
+			if r == 0 {
+				r, a = ' ', 0
+			}
+			b = b && r == ' ' && a == 0
+			h = (h ^ uint64(r)) * prime64
+			h = (h ^ uint64(a)) * prime64
+		}
+		hs = append(hs, h)
+		blank = append(blank, b)
+	}
+	return hs, blank
+}
+
+// rowEq returns true if row y of the receiver has the same contents as row
+// oy of the other grid (compared under the same normalization as rowHashes).
+func (g Grid) rowEq(other Grid, y, oy int) bool {
+	i, j := y*g.Size.X, oy*other.Size.X
+	for x := 0; x < g.Size.X; x, i, j = x+1, i+1, j+1 {
+		r, a := g.Rune[i], g.Attr[i]
+		or, oa := other.Rune[j], other.Attr[j]
+		if r == 0 {
+			r, a = ' ', 0
+		}
+		if or == 0 {
+			or, oa = ' ', 0
+		}
+		if r != or || a != oa {
+			return false
+		}
+	}
+	return true
+}
+
+// copy returns a copy of the grid that shares no cell data with the receiver.
+func (g Grid) copy() Grid {
+	return Grid{
+		Size: g.Size,
+		Attr: append([]ansi.SGRAttr(nil), g.Attr...),
+		Rune: append([]rune(nil), g.Rune...),
+	}
+}
+
+// shiftRows moves the contents of the 0-indexed [top, bottom) row range up by
+// k rows (down if negative), clearing any rows exposed by the move; this is
+// what a terminal does when scrolling within a region.
+func (g Grid) shiftRows(top, bottom, k int) {
+	if top >= bottom {
+		return
+	}
+	g.markDirty(top, bottom)
+	w := g.Size.X
+	switch {
+	case k > 0:
+		if k > bottom-top {
+			k = bottom - top
+		}
+		copy(g.Rune[top*w:bottom*w], g.Rune[(top+k)*w:bottom*w])
+		copy(g.Attr[top*w:bottom*w], g.Attr[(top+k)*w:bottom*w])
+		if len(g.wrapped) == g.Size.Y {
+			copy(g.wrapped[top:bottom], g.wrapped[top+k:bottom])
+		}
+		g.clearRows(bottom-k, bottom)
+	case k < 0:
+		if k = -k; k > bottom-top {
+			k = bottom - top
+		}
+		copy(g.Rune[(top+k)*w:bottom*w], g.Rune[top*w:(bottom-k)*w])
+		copy(g.Attr[(top+k)*w:bottom*w], g.Attr[top*w:(bottom-k)*w])
+		if len(g.wrapped) == g.Size.Y {
+			copy(g.wrapped[top+k:bottom], g.wrapped[top:bottom-k])
+		}
+		g.clearRows(top, top+k)
+	}
+}
+
+// clearRows zeros all cells in the 0-indexed [top, bottom) row range.
+func (g Grid) clearRows(top, bottom int) {
+	g.markDirty(top, bottom)
+	if len(g.wrapped) == g.Size.Y {
+		for y := top; y < bottom; y++ {
+			g.wrapped[y] = false
+		}
+	}
+	w := g.Size.X
+	for i := top * w; i < bottom*w; i++ {
+		g.Rune[i] = 0
+		g.Attr[i] = 0
+	}
+}
@@ -0,0 +1,249 @@
+package anansi
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+
+	"github.com/jcorbin/anansi/ansi"
+)
+
+// ImageMode determines how DrawImage maps blocks of image pixels onto cells,
+// each of which may show at most two colors: its foreground color for the
+// pixels covered by its block element rune, and its background color for the
+// rest.
+type ImageMode int
+
+// Image modes
+const (
+	// ImageHalfBlocks renders 1x2 pixels per cell, using upper half block
+	// runes; this is the most widely supported mode, since the two pixels can
+	// always be rendered exactly.
+	ImageHalfBlocks ImageMode = iota
+
+	// ImageQuadrants renders 2x2 pixels per cell, choosing the quadrant block
+	// rune and two colors that best approximate each cell.
+	ImageQuadrants
+
+	// ImageSextants renders 2x3 pixels per cell, choosing the sextant block
+	// rune (from Unicode's "Symbols for Legacy Computing", which not all
+	// fonts support) and two colors that best approximate each cell.
+	ImageSextants
+)
+
+// CellSize returns the size of the pixel block covered by each cell.
+func (mode ImageMode) CellSize() image.Point {
+	switch mode {
+	case ImageQuadrants:
+		return image.Pt(2, 2)
+	case ImageSextants:
+		return image.Pt(2, 3)
+	default:
+		return image.Pt(1, 2)
+	}
+}
+
+// Rune returns the block element rune that covers the pixels selected by the
+// given mask, whose bits number the cell's pixels in row-major order.
+func (mode ImageMode) Rune(mask uint) rune {
+	switch mode {
+	case ImageQuadrants:
+		return quadrantRunes[mask&0xf]
+	case ImageSextants:
+		switch mask &= 0x3f; mask {
+		case 0:
+			return ' '
+		case 0x3f:
+			return '█'
+		case 0x15:
+			return '▌'
+		case 0x2a:
+			return '▐'
+		}
+		// sextants are encoded in mask order, skipping the half blocks
+		r := rune(0x1FB00 + mask - 1)
+		if mask > 0x15 {
+			r--
+		}
+		if mask > 0x2a {
+			r--
+		}
+		return r
+	default:
+		return [4]rune{' ', '▀', '▄', '█'}[mask&3]
+	}
+}
+
+var quadrantRunes = [16]rune{
+	' ', '▘', '▝', '▀',
+	'▖', '▌', '▞', '▛',
+	'▗', '▚', '▐', '▜',
+	'▄', '▙', '▟', '█',
+}
+
+// ImageCells returns the size of the cell grid needed to draw an image of the
+// given pixel size.
+func (mode ImageMode) ImageCells(size image.Point) image.Point {
+	cs := mode.CellSize()
+	return image.Pt((size.X+cs.X-1)/cs.X, (size.Y+cs.Y-1)/cs.Y)
+}
+
+// DrawImage draws an image into the grid, with its top-left cell at the
+// given screen point, clipping to the grid's bounds. Each cell's pixels are
+// partitioned into the two groups that best approximate them, whose average
+// colors are then converted through the given color model (if not nil), e.g.
+// to target a limited palette like ansi.Palette8. Pixels past the right or
+// bottom edge of the image take the existing background color of their cell.
+func DrawImage(g Grid, at ansi.Point, img image.Image, mode ImageMode, cm ansi.ColorModel) {
+	cs := mode.CellSize()
+	ib := img.Bounds()
+	sz := mode.ImageCells(ib.Size())
+	px := make([]ansi.SGRColor, cs.X*cs.Y)
+	for y := 0; y < sz.Y; y++ {
+		for x := 0; x < sz.X; x++ {
+			pt := at
+			pt.Point = pt.Point.Add(image.Pt(x, y))
+			i, ok := g.CellOffset(pt)
+			if !ok {
+				continue
+			}
+
+			bg, _ := g.Attr[i].BG()
+			p0 := ib.Min.Add(image.Pt(x*cs.X, y*cs.Y))
+			for j := range px {
+				if p := p0.Add(image.Pt(j%cs.X, j/cs.X)); p.In(ib) {
+					px[j] = colorOf(img.At(p.X, p.Y))
+				} else {
+					px[j] = bg
+				}
+			}
+
+			mask, fg, bg := partitionColors(px)
+			if cm != nil {
+				fg, bg = cm.Convert(fg), cm.Convert(bg)
+			}
+			if fg == bg {
+				mask = 0
+			}
+			attr := bg.BG()
+			if mask != 0 {
+				attr |= fg.FG()
+			}
+			g.Rune[i], g.Attr[i] = mode.Rune(mask), attr
+			g.markDirty(pt.Y-1, pt.Y)
+		}
+	}
+}
+
+// ImagePlacement places an inline image over a rectangle of screen cells in
+// a ScreenState; the grid cells under it aren't repainted by Screen while it
+// remains in place, and it's only redrawn once replaced, moved, or after a
+// full redraw. Its Data must not be modified once placed.
+type ImagePlacement struct {
+	// Rect is the cells covered by the image.
+	Rect ansi.Rectangle
+
+	// Data is the encoded image, written with the cursor at Rect.Min, e.g.
+	// as built by ansi.ImageProtocols.AppendImage.
+	Data []byte
+
+	// Erase, if not empty, is written once the image is no longer placed,
+	// e.g. as built by ansi.AppendKittyDelete; otherwise the image is only
+	// erased by repainting the cells under it.
+	Erase []byte
+}
+
+// indexIn returns the index of an identical placement, or -1 if there's none.
+func (im ImagePlacement) indexIn(ims []ImagePlacement) int {
+	for i := range ims {
+		if ims[i].Rect == im.Rect && bytes.Equal(ims[i].Data, im.Data) {
+			return i
+		}
+	}
+	return -1
+}
+
+// maskCells updates prior cells under the image to match g if same is true,
+// so that they're not repainted, or to never match it otherwise, so that
+// they're repainted (also marking them dirty).
+func (im ImagePlacement) maskCells(g, prior Grid, same bool) {
+	r := im.Rect
+	for pt := r.Min; pt.Y < r.Max.Y; pt.Y++ {
+		for pt.X = r.Min.X; pt.X < r.Max.X; pt.X++ {
+			i, ok := g.CellOffset(pt)
+			if !ok {
+				continue
+			}
+			if same {
+				prior.Rune[i], prior.Attr[i] = g.Rune[i], g.Attr[i]
+			} else {
+				prior.Rune[i] = -1
+				g.markDirty(pt.Y-1, pt.Y)
+			}
+		}
+	}
+}
+
+// hideCells marks the cells under the image as unknown in g, so that cursor
+// movement never re-writes them, which would draw over the image.
+func (im ImagePlacement) hideCells(g Grid) {
+	r := im.Rect
+	for pt := r.Min; pt.Y < r.Max.Y; pt.Y++ {
+		for pt.X = r.Min.X; pt.X < r.Max.X; pt.X++ {
+			if i, ok := g.CellOffset(pt); ok {
+				g.Rune[i] = -1
+			}
+		}
+	}
+}
+
+func colorOf(c color.Color) ansi.SGRColor {
+	return ansi.RGBA(c.RGBA())
+}
+
+// partitionColors finds the partition of colors into (foreground, background)
+// groups that minimizes the sum of squared differences from each group's
+// average color; returns the mask of foreground members and the two averages.
+// The last color is always kept in the background group, so that uniform
+// colors produce a zero mask.
+func partitionColors(px []ansi.SGRColor) (mask uint, fg, bg ansi.SGRColor) {
+	var rgb [8][3]int
+	for i, c := range px {
+		r, g, b := c.RGB()
+		rgb[i] = [3]int{int(r), int(g), int(b)}
+	}
+	n := uint(len(px))
+	best := -1
+	for m := uint(0); m < 1<<(n-1); m++ {
+		var sum, sq [2][3]int
+		var count [2]int
+		for i := uint(0); i < n; i++ {
+			k := m >> i & 1
+			count[k]++
+			for j, v := range rgb[i] {
+				sum[k][j] += v
+				sq[k][j] += v * v
+			}
+		}
+		cost := 0
+		for k := range count {
+			if count[k] > 0 {
+				for j := range sum[k] {
+					cost += sq[k][j] - sum[k][j]*sum[k][j]/count[k]
+				}
+			}
+		}
+		if best < 0 || cost < best {
+			best, mask = cost, m
+			fg, bg = meanColor(sum[1], count[1]), meanColor(sum[0], count[0])
+		}
+	}
+	return mask, fg, bg
+}
+
+func meanColor(sum [3]int, n int) ansi.SGRColor {
+	if n == 0 {
+		return ansi.RGB(0, 0, 0)
+	}
+	return ansi.RGB(uint8(sum[0]/n), uint8(sum[1]/n), uint8(sum[2]/n))
+}
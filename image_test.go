@@ -0,0 +1,124 @@
+package anansi_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/ansi"
+	anansitest "github.com/jcorbin/anansi/test"
+)
+
+func TestDrawImage(t *testing.T) {
+	var (
+		red   = color.RGBA{0xff, 0, 0, 0xff}
+		blue  = color.RGBA{0, 0, 0xff, 0xff}
+		white = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	)
+	img := func(w int, cs ...color.RGBA) image.Image {
+		m := image.NewRGBA(image.Rect(0, 0, w, len(cs)/w))
+		for i, c := range cs {
+			m.SetRGBA(i%w, i/w, c)
+		}
+		return m
+	}
+	rgb := func(c color.RGBA) ansi.SGRColor { return ansi.RGB(c.R, c.G, c.B) }
+
+	for _, tc := range []struct {
+		name  string
+		img   image.Image
+		mode  ImageMode
+		cm    ansi.ColorModel
+		runes [][]rune
+		attrs [][]ansi.SGRAttr
+	}{
+		{
+			name:  "half blocks",
+			img:   img(2, red, red, blue, red),
+			mode:  ImageHalfBlocks,
+			runes: [][]rune{{'▀', ' '}},
+			attrs: [][]ansi.SGRAttr{{rgb(red).FG() | rgb(blue).BG(), rgb(red).BG()}},
+		},
+
+		{
+			name:  "half blocks partial",
+			img:   img(1, red, blue, white),
+			mode:  ImageHalfBlocks,
+			runes: [][]rune{{'▀'}, {'▀'}},
+			attrs: [][]ansi.SGRAttr{
+				{rgb(red).FG() | rgb(blue).BG()},
+				{rgb(white).FG() | ansi.RGB(0, 0, 0).BG()},
+			},
+		},
+
+		{
+			name:  "quadrants",
+			img:   img(2, red, red, red, white),
+			mode:  ImageQuadrants,
+			runes: [][]rune{{'▛'}},
+			attrs: [][]ansi.SGRAttr{{rgb(red).FG() | rgb(white).BG()}},
+		},
+
+		{
+			name:  "sextants",
+			img:   img(2, red, white, red, white, red, white),
+			mode:  ImageSextants,
+			runes: [][]rune{{'▌'}},
+			attrs: [][]ansi.SGRAttr{{rgb(red).FG() | rgb(white).BG()}},
+		},
+
+		{
+			name:  "sextant",
+			img:   img(2, red, red, white, white, white, white),
+			mode:  ImageSextants,
+			runes: [][]rune{{'\U0001FB02'}},
+			attrs: [][]ansi.SGRAttr{{rgb(red).FG() | rgb(white).BG()}},
+		},
+
+		{
+			name:  "color model",
+			img:   img(1, color.RGBA{0xf0, 0x10, 0x10, 0xff}, color.RGBA{0xf0, 0xf0, 0xf0, 0xff}),
+			mode:  ImageHalfBlocks,
+			cm:    ansi.Palette3,
+			runes: [][]rune{{'▀'}},
+			attrs: [][]ansi.SGRAttr{{ansi.Palette3[1].FG() | ansi.Palette3[7].BG()}},
+		},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			var g Grid
+			g.Resize(tc.mode.ImageCells(tc.img.Bounds().Size()))
+			DrawImage(g, ansi.Pt(1, 1), tc.img, tc.mode, tc.cm)
+			rs, as := anansitest.GridRowData(g)
+			assert.Equal(t, tc.runes, rs, "expected runes")
+			assert.Equal(t, tc.attrs, as, "expected attrs")
+		}))
+	}
+}
+
+func TestScreen_imageCells(t *testing.T) {
+	var sc Screen
+	sc.Resize(image.Pt(10, 3))
+	var out bytes.Buffer
+	frame := func(s string) string {
+		sc.Clear()
+		sc.To(ansi.Pt(1, 2))
+		sc.WriteString(s)
+		sc.Images = append(sc.Images[:0], ImagePlacement{
+			Rect:  ansi.Rect(2, 2, 4, 3),
+			Data:  []byte("<img>"),
+			Erase: []byte("<del>"),
+		})
+		out.Reset()
+		_, err := sc.WriteTo(&out)
+		require.NoError(t, err)
+		return out.String()
+	}
+	assert.Equal(t, "\x1b[?25l\x1b[2J\x1b[2;1H\x1b[0mabcde\ra<img>", frame("abcde"))
+	// cells under the kept image must not be re-written to move over them
+	assert.Equal(t, "\x1b[2;1HX\x1b[3Cf", frame("Xbcdf"))
+}
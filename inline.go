@@ -0,0 +1,247 @@
+package anansi
+
+import (
+	"bytes"
+	"image"
+	"io"
+
+	"github.com/jcorbin/anansi/ansi"
+)
+
+// InlineScreen provides differential updating of a region of lines inline
+// with a terminal's normal output, rather than of the whole screen; e.g. for
+// progress bars and status lines, without raw mode or the alternate screen.
+//
+// The region's size is set by Resize, and its lines are reserved by the next
+// WriteTo, starting at the cursor's line (which should be empty, e.g. after
+// the last line output by the program). Only relative cursor movement is used
+// within the region, so it may be rendered before its position is known.
+// Lines written by Print are output above the region, which moves down to
+// make room, scrolling the terminal as necessary.
+//
+// Image placements are not supported.
+type InlineScreen struct {
+	Screen
+
+	// Top is the terminal row of the first line of the region, once known;
+	// a cursor position query is sent after reserving lines, whose reply
+	// should be passed to ProcessCPR. It's then used to move back into the
+	// region after an output error.
+	Top int
+
+	above    bytes.Buffer // lines pending output above the region
+	reserved int          // number of lines reserved on the terminal
+	row      int          // row within the region of the terminal cursor, if > 0
+	query    bool         // whether a cursor position reply is pending
+	finish   bool         // whether the next update leaves the region
+}
+
+// Resize the region, causing it to be reserved anew (and fully redrawn) by
+// the next WriteTo.
+func (in *InlineScreen) Resize(size image.Point) bool {
+	if size.Y < 0 {
+		size.Y = 0
+	}
+	return in.Screen.Resize(size)
+}
+
+// Print adds a line of output (with a trailing newline added if it doesn't
+// have one) to be written above the region by the next WriteTo.
+func (in *InlineScreen) Print(p []byte) {
+	for len(p) > 0 {
+		line := p
+		if i := bytes.IndexByte(p, '\n'); i >= 0 {
+			line, p = p[:i], p[i+1:]
+		} else {
+			p = nil
+		}
+		in.above.Write(bytes.TrimSuffix(line, []byte("\r")))
+		in.above.WriteString("\r\n")
+	}
+}
+
+// ProcessCPR processes a cursor position report (as decoded by
+// ansi.DecodeEscape), setting Top if a reply to the position query sent after
+// reserving lines is pending; returns true if the report was consumed.
+//
+// NOTE a CPR is indistinguishable from a modified F3 key, so callers should
+// only expect one while a reply is pending.
+func (in *InlineScreen) ProcessCPR(e ansi.Escape, a []byte) bool {
+	if e != ansi.CPR || !in.query {
+		return false
+	}
+	pt, ok := decodePosition(a)
+	if !ok || pt.Y < 1 {
+		return false
+	}
+	in.query = false
+	if in.reserved > 0 {
+		// the cursor was on the region's last line when queried
+		in.Top = pt.Y - in.reserved + 1
+	}
+	return true
+}
+
+// Pending returns true if a cursor position reply is pending.
+func (in *InlineScreen) Pending() bool { return in.query }
+
+// Finish causes the next WriteTo to leave the region: after drawing any final
+// update, the cursor is left on the line below it, so that its last state
+// remains part of the terminal's normal output (and scrollback). Any further
+// use of the InlineScreen then reserves a new region.
+func (in *InlineScreen) Finish() {
+	in.finish = true
+}
+
+// Enter does nothing; InlineScreen implements Context to leave its region on
+// Exit.
+func (in *InlineScreen) Enter(term *Term) error { return nil }
+
+// Exit leaves the region, writing any final update to the terminal; see
+// Finish.
+func (in *InlineScreen) Exit(term *Term) error {
+	if in.reserved == 0 && in.above.Len() == 0 {
+		return nil
+	}
+	in.Finish()
+	_, err := in.WriteTo(term.File)
+	return err
+}
+
+// WriteTo builds and writes output based on the current ScreenState: any
+// lines pending from Print are written above the region, then its lines are
+// reserved as necessary, and finally any changed lines are drawn. If the
+// internal output buffer isn't empty, then the build step is skipped, and
+// another attempt is made to flush the output buffer; the next update is then
+// made against the state that was built into it, not any since.
+func (in *InlineScreen) WriteTo(w io.Writer) (n int64, err error) {
+	if in.out.buf.Len() == 0 {
+		in.update()
+		in.next.Resize(in.Grid.Bounds().Size())
+		copy(in.next.Rune, in.Grid.Rune)
+		copy(in.next.Attr, in.Grid.Attr)
+		in.Grid.clearDirty()
+	}
+	n, err = in.out.WriteTo(w)
+	if err == nil {
+		in.partial = false
+		in.prior, in.next = in.next, in.prior
+		if in.finish {
+			in.finish = false
+			in.Invalidate()
+		}
+	} else if isEWouldBlock(err) {
+		in.partial = true
+	} else {
+		in.partial = false
+		in.out.Reset()
+		in.Invalidate()
+		in.row = 0
+		if in.Top == 0 {
+			// lost track of the region, so reserve a new one
+			in.reserved = 0
+		}
+	}
+	return n, err
+}
+
+func (in *InlineScreen) update() {
+	cur, buf := &in.out.CursorState, &in.out.buf
+	h := in.Grid.Size.Y
+
+	buf.WriteSeq(cur.Hide())
+	full := len(in.prior.Rune) == 0 || in.prior.Size != in.Grid.Size
+	if in.above.Len() > 0 || in.reserved != h {
+		// clear any reserved lines, write lines above, and reserve anew
+		if in.reserved > 0 {
+			in.moveToRow(1)
+			buf.WriteSGR(cur.MergeSGR(0))
+			buf.WriteESC(ansi.ED)
+		} else {
+			buf.WriteByte('\r')
+		}
+		if in.above.Len() > 0 {
+			buf.WriteSGR(cur.MergeSGR(0))
+			in.above.WriteTo(buf)
+			cur.attrKnown = false
+		}
+		in.reserved, in.row, in.Top = h, 1, 0
+		for ; in.row < h; in.row++ {
+			buf.WriteByte('\n')
+		}
+		if h > 0 {
+			buf.WriteSeq(ansi.DSR.WithInts(6))
+			in.query = true
+		}
+		full = true
+	}
+
+	for y := 1; y <= h; y++ {
+		if !full && in.Grid.rowEq(in.prior, y-1, y-1) {
+			continue
+		}
+		in.drawRow(y)
+	}
+
+	if in.finish {
+		if h > 0 {
+			in.moveToRow(h)
+			buf.WriteSGR(cur.MergeSGR(0))
+			buf.WriteString("\r\n")
+		}
+		in.reserved, in.row, in.Top, in.query = 0, 0, 0, false
+		buf.WriteSeq(cur.Show())
+		return
+	}
+
+	if uc := in.UserCursor; uc.Visible && uc.Point.Valid() && uc.Y <= h {
+		in.moveToRow(uc.Y)
+		if uc.X > 1 {
+			buf.WriteSeq(ansi.CHA.WithInts(uc.X))
+		}
+		buf.WriteSGR(cur.MergeSGR(uc.Attr))
+		buf.WriteSeq(cur.Style(uc.Shape, uc.Blink))
+		buf.WriteSeq(cur.Show())
+	}
+}
+
+// drawRow draws a line of the region, erasing any of it that's left blank.
+func (in *InlineScreen) drawRow(y int) {
+	cur, buf, g := &in.out.CursorState, &in.out.buf, in.Grid
+	in.moveToRow(y)
+	i := (y - 1) * g.Size.X
+	end := g.Size.X
+	for ; end > 0; end-- {
+		if r, a := g.Rune[i+end-1], g.Attr[i+end-1]; (r != 0 && r != ' ') || a != 0 {
+			break
+		}
+	}
+	for x := 0; x < end; x++ {
+		r, a := g.Rune[i+x], g.Attr[i+x]
+		if r == 0 {
+			r, a = ' ', 0
+		}
+		buf.WriteSGR(cur.MergeSGR(a))
+		buf.WriteRune(r)
+	}
+	if end < g.Size.X {
+		buf.WriteSGR(cur.MergeSGR(0))
+		buf.WriteESC(ansi.EL)
+	}
+}
+
+// moveToRow moves the terminal cursor to the start of a line in the region,
+// relative to its current line if known, or absolutely from Top otherwise.
+func (in *InlineScreen) moveToRow(y int) {
+	buf := &in.out.buf
+	buf.WriteByte('\r')
+	switch {
+	case in.row > 0 && y < in.row:
+		buf.WriteSeq(ansi.CUU.WithInts(in.row - y))
+	case in.row > 0 && y > in.row:
+		buf.WriteSeq(ansi.CUD.WithInts(y - in.row))
+	case in.row == 0 && in.Top > 0:
+		buf.WriteSeq(ansi.CUP.WithInts(in.Top+y-1, 1))
+	}
+	in.row = y
+}
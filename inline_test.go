@@ -0,0 +1,177 @@
+package anansi_test
+
+import (
+	"image"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/ansi"
+	anansitest "github.com/jcorbin/anansi/test"
+)
+
+func TestInlineScreen(t *testing.T) {
+	type step struct {
+		run   func(in *InlineScreen)
+		lines []string
+		row   int
+		top   int
+	}
+	setLine := func(in *InlineScreen, y int, s string) {
+		for x := 1; x <= in.Grid.Size.X; x++ {
+			in.Grid.Set(ansi.Pt(x, y), 0, 0)
+		}
+		for i, r := range []rune(s) {
+			in.Grid.Set(ansi.Pt(i+1, y), r, 0)
+		}
+	}
+	for _, tc := range []struct {
+		name  string
+		size  image.Point
+		steps []step
+	}{
+		{"progress", image.Pt(8, 5), []step{
+			{func(in *InlineScreen) {
+				in.Resize(image.Pt(8, 2))
+				setLine(in, 1, "status")
+				setLine(in, 2, "[##  ]")
+			}, []string{"$ run", "status", "[##  ]", "", ""}, 3, 2},
+			{func(in *InlineScreen) {
+				setLine(in, 2, "[### ]")
+			}, []string{"$ run", "status", "[### ]", "", ""}, 3, 2},
+			{func(in *InlineScreen) {
+				in.Print([]byte("log 1\nlog 2"))
+				setLine(in, 1, "busy")
+			}, []string{"$ run", "log 1", "log 2", "busy", "[### ]"}, 5, 4},
+			{func(in *InlineScreen) {
+				in.Print([]byte("log 3\n"))
+			}, []string{"log 1", "log 2", "log 3", "busy", "[### ]"}, 5, 4},
+			{func(in *InlineScreen) {
+				setLine(in, 1, "done")
+				setLine(in, 2, "")
+				in.Finish()
+			}, []string{"log 2", "log 3", "done", "", ""}, 5, 0},
+		}},
+
+		{"grow and shrink", image.Pt(8, 6), []step{
+			{func(in *InlineScreen) {
+				in.Resize(image.Pt(8, 1))
+				setLine(in, 1, "one")
+			}, []string{"$ run", "one", "", "", "", ""}, 2, 2},
+			{func(in *InlineScreen) {
+				in.Resize(image.Pt(8, 3))
+				setLine(in, 1, "one")
+				setLine(in, 2, "two")
+				setLine(in, 3, "three")
+			}, []string{"$ run", "one", "two", "three", "", ""}, 4, 2},
+			{func(in *InlineScreen) {
+				in.Resize(image.Pt(8, 1))
+				setLine(in, 1, "four")
+			}, []string{"$ run", "four", "", "", "", ""}, 2, 2},
+		}},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			replies := make(chan []byte, 1)
+			var term Terminal
+			term.Replies = replies
+			term.Resize(tc.size)
+			term.WriteString("$ run\r\n")
+
+			var in InlineScreen
+			for i, step := range tc.steps {
+				step.run(&in)
+				_, err := in.WriteTo(&term)
+				require.NoError(t, err, "[%d] unexpected write error", i)
+				select {
+				case reply := <-replies:
+					e, a, _ := ansi.DecodeEscape(reply)
+					assert.True(t, in.ProcessCPR(e, a), "[%d] expected CPR to be processed", i)
+				default:
+				}
+				lines := anansitest.GridLines(term.Grid, ' ')
+				for j := range lines {
+					lines[j] = strings.TrimRight(lines[j], " ")
+				}
+				assert.Equal(t, step.lines, lines, "[%d] expected lines", i)
+				assert.Equal(t, step.row, term.Y, "[%d] expected cursor row", i)
+				assert.Equal(t, step.top, in.Top, "[%d] expected top", i)
+			}
+		}))
+	}
+}
+
+// stallWriter writes at most n bytes to w, failing with EWOULDBLOCK after
+// that, as a non-blocking file would once its buffer is full.
+type stallWriter struct {
+	w io.Writer
+	n int
+}
+
+func (sw *stallWriter) Write(p []byte) (int, error) {
+	if len(p) <= sw.n {
+		sw.n -= len(p)
+		return sw.w.Write(p)
+	}
+	n, _ := sw.w.Write(p[:sw.n])
+	sw.n -= n
+	return n, syscall.EWOULDBLOCK
+}
+
+func TestInlineScreen_partial(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		reset bool
+	}{
+		{"redraw", false},
+		{"reset", true},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			var term Terminal
+			term.Resize(image.Pt(8, 3))
+			term.WriteString("$ run\r\n")
+
+			var in InlineScreen
+			in.Resize(image.Pt(8, 1))
+			draw := func(s string) {
+				if tc.reset {
+					in.Reset()
+				}
+				for i, r := range s {
+					in.Grid.Set(ansi.Pt(i+1, 1), r, 0)
+				}
+			}
+			lines := func() []string {
+				lines := anansitest.GridLines(term.Grid, ' ')
+				for j := range lines {
+					lines[j] = strings.TrimRight(lines[j], " ")
+				}
+				return lines
+			}
+
+			draw("aaaa")
+			_, err := in.WriteTo(&term)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"$ run", "aaaa", ""}, lines())
+
+			// the next update is only partially written...
+			draw("bbbb")
+			_, err = in.WriteTo(&stallWriter{w: &term, n: 3})
+			require.Equal(t, syscall.EWOULDBLOCK, err)
+
+			// ...before drawing another, which must still be written once
+			// the partial update completes
+			draw("cccc")
+			_, err = in.WriteTo(&term)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"$ run", "bbbb", ""}, lines(), "expected partial update to complete")
+			_, err = in.WriteTo(&term)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"$ run", "cccc", ""}, lines(), "expected next update")
+		}))
+	}
+}
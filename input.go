@@ -2,6 +2,7 @@ package anansi
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -16,34 +17,52 @@ import (
 // NewInput creates an Input around the given file; the optional minRead
 // argument defaults to 128.
 func NewInput(f *os.File, minRead int) *Input {
-	// TODO consider specializing to *os.File
+	in := NewInputReader(nil, minRead)
+	if f != nil {
+		in.file, in.r = f, f
+	}
+	return in
+}
+
+// NewInputReader creates an Input around any io.Reader, e.g. a network stream
+// or test input; the optional minRead argument defaults to 128. Non-blocking
+// and timed reads are supported by reading from it in a separate goroutine,
+// once first needed.
+func NewInputReader(r io.Reader, minRead int) *Input {
 	if minRead == 0 {
 		minRead = 128
 	}
-	in := &Input{
-		file:    f,
+	return &Input{
+		r:       r,
 		minRead: minRead,
 	}
-	return in
 }
 
-// Input supports reading terminal input from a file handle with a buffer for
-// things like escape sequences. It supports both blocking and non-blocking
-// reads. It is not safe to use Input in parallel from multiple goroutines,
-// such users need to layer a lock around an Input.
+// Input supports reading terminal input from a file handle (or any io.Reader)
+// with a buffer for things like escape sequences. It supports blocking,
+// non-blocking, and timed reads; files are polled for readiness, rather than
+// changing their status flags. It is not safe to use Input in parallel from
+// multiple goroutines, such users need to layer a lock around an Input.
 type Input struct {
-	file *os.File
+	file *os.File  // if not nil, polled for readiness
+	r    io.Reader // the file, or any other reader
+	pump chan inputChunk
 
-	ateof    bool
-	minRead  int
-	nonblock bool
-	buf      bytes.Buffer
+	ateof   bool
+	minRead int
+	buf     bytes.Buffer
 
 	rec    io.Writer
 	recTmp bytes.Buffer
 }
 
-var errNoFd = errors.New("non-blocking io not supported by underlying reader")
+// inputChunk is a result read by an Input pump goroutine.
+type inputChunk struct {
+	b   []byte
+	err error
+}
+
+var errNoReader = errors.New("anansi.Input has no reader")
 
 // SetRecording enables (or disables if nil-argument) input recording. When
 // enabled, all read bytes are written to the given destination with added
@@ -85,10 +104,18 @@ const (
 	recReadErrSize  = 2 + 11 + 256 + 2
 )
 
-type fdProvider interface {
-	Fd() uintptr
+// pollFd is a struct pollfd, as used by poll.
+type pollFd struct {
+	fd      int32
+	events  int16
+	revents int16
 }
 
+const (
+	pollIn  = 0x1 // POLLIN
+	pollOut = 0x4 // POLLOUT
+)
+
 // AtEOF returns true if the last input read returned io.EOF.
 func (in *Input) AtEOF() bool {
 	return in.ateof
@@ -142,28 +169,31 @@ func (in *Input) DecodeRune() (rune, bool) {
 // at least one new byte has been read. Returns the number of bytes read and
 // any error.
 func (in *Input) ReadMore() (int, error) {
-	// TODO opportunistically read in non-blocking mode if set, only
-	//      transitioning to blocking if needed
-	if err := in.setNonblock(false); err != nil {
-		return 0, err
-	}
+	return in.ReadMoreContext(context.Background())
+}
+
+// ReadMoreContext is like ReadMore, but returns promptly with the context's
+// error once it's done, e.g. so that a goroutine blocked on input may be
+// cancelled during shutdown. Files are polled along with a pipe that's
+// written to once the context is done; any other reader is read by a separate
+// goroutine, whose results are then waited on along with the context.
+func (in *Input) ReadMoreContext(ctx context.Context) (int, error) {
 	for {
-		p := in.readBuf()
-		n, err := in.file.Read(p)
+		var frm InputFrame
+		n, err := in.read(ctx, -1, &frm)
+		if err != nil && err == ctx.Err() {
+			return 0, err
+		}
 		if ateof := err == io.EOF; in.ateof && ateof {
 			// TODO if n > 0 the io.Reader is being misbehaved... do we care?
 			return 0, io.EOF
 		} else if in.ateof = ateof; ateof {
 			err = nil
 		}
-		var frm InputFrame
 		if in.rec != nil {
 			frm.T = time.Now()
 		}
 		frm.E = err
-		if n > 0 {
-			frm.B = p[:n]
-		}
 		err = in.write(frm)
 		if n > 0 || err != nil {
 			return n, err
@@ -171,20 +201,78 @@ func (in *Input) ReadMore() (int, error) {
 	}
 }
 
+// InputEvent is a unit of decoded input, as delivered by Input.Events: either
+// an escape sequence, a rune, or a final read error.
+type InputEvent struct {
+	E   ansi.Escape // escape identifier, if non-zero
+	A   []byte      // escape argument, if any
+	R   rune        // rune, if E is zero
+	Err error       // read error, if non-nil this is the last event
+}
+
+// Events starts a goroutine that reads and decodes input, delivering each
+// escape sequence and rune on the returned channel; it's an alternative to
+// calling ReadMore and decoding directly, for applications that prefer a
+// select-based event loop. A read error (e.g. io.EOF) is delivered as a final
+// event, and the channel is closed once the goroutine stops after it, or after
+// the context is done. The Input must not be otherwise used until then.
+func (in *Input) Events(ctx context.Context) <-chan InputEvent {
+	ch := make(chan InputEvent)
+	go func() {
+		defer close(ch)
+		send := func(ev InputEvent) bool {
+			select {
+			case ch <- ev:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		for {
+			for {
+				var ev InputEvent
+				if e, a := in.DecodeEscape(); e != 0 {
+					ev.E, ev.A = e, append([]byte(nil), a...)
+				} else if r, ok := in.DecodeRune(); ok {
+					ev.R = r
+				} else {
+					break
+				}
+				if !send(ev) {
+					return
+				}
+			}
+			if _, err := in.ReadMoreContext(ctx); err != nil {
+				if err != ctx.Err() {
+					send(InputEvent{Err: err})
+				}
+				return
+			}
+		}
+	}()
+	return ch
+}
+
 // ReadAny available bytes from the underlying file into the internal byte
-// buffer; uses non-blocking reads. Returns the number of bytes read and any
-// error.
+// buffer, without blocking. Returns the number of bytes read and any error.
 func (in *Input) ReadAny() (int, error) {
-	if err := in.setNonblock(true); err != nil {
-		return 0, err
+	return in.ReadTimeout(0)
+}
+
+// ReadTimeout reads available bytes from the underlying file into the
+// internal byte buffer, waiting up to the given duration for any to become
+// available. Returns the number of bytes read (0 if the timeout expired) and
+// any error.
+func (in *Input) ReadTimeout(timeout time.Duration) (int, error) {
+	if timeout < 0 {
+		timeout = 0
 	}
 	in.ateof = false
 	var frm InputFrame
 	if in.rec != nil {
 		frm.T = time.Now()
 	}
-	p := in.readBuf()
-	n, err := in.file.Read(p)
+	n, err := in.read(context.Background(), timeout, &frm)
 	if isEWouldBlock(err) {
 		err = nil
 	}
@@ -192,27 +280,160 @@ func (in *Input) ReadAny() (int, error) {
 		err = nil
 	}
 	frm.E = err
+	err = in.write(frm)
+	return n, err
+}
+
+// read reads once from the underlying reader, waiting up to timeout (forever
+// if negative) for input to become available, or until the context is done;
+// any bytes read are set in frm.
+func (in *Input) read(ctx context.Context, timeout time.Duration, frm *InputFrame) (int, error) {
+	if in.r == nil {
+		return 0, errNoReader
+	}
+	done := ctx.Done()
+	if in.pump == nil && in.file == nil && (timeout >= 0 || done != nil) {
+		in.startPump()
+	}
+	if in.pump != nil {
+		return in.readPump(ctx, timeout, frm)
+	}
+	// files are always polled, rather than relying on a blocking read,
+	// since they may be made non-blocking by others (e.g. Output.NoStall)
+	if in.file != nil {
+		if ready, err := pollFile(in.file, done, timeout); err != nil {
+			return 0, err
+		} else if !ready {
+			return 0, ctx.Err()
+		}
+	}
+	p := in.readBuf()
+	n, err := in.r.Read(p)
 	if n > 0 {
 		frm.B = p[:n]
 	}
-	err = in.write(frm)
 	return n, err
 }
 
-// Enter is a no-op.
+// pollFile waits up to timeout for the file to become readable, or until the
+// done channel is closed; its descriptor is accessed through SyscallConn,
+// since Fd would put it into blocking mode.
+func pollFile(f *os.File, done <-chan struct{}, timeout time.Duration) (ready bool, err error) {
+	rc, err := f.SyscallConn()
+	if err != nil {
+		return false, err
+	}
+
+	var fds [2]pollFd
+	nfds := 1
+	if done != nil {
+		var wake [2]int
+		syscall.ForkLock.RLock()
+		err = syscall.Pipe(wake[:])
+		if err == nil {
+			syscall.CloseOnExec(wake[0])
+			syscall.CloseOnExec(wake[1])
+		}
+		syscall.ForkLock.RUnlock()
+		if err != nil {
+			return false, err
+		}
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-done:
+				_, _ = syscall.Write(wake[1], []byte{0})
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-stopped
+			syscall.Close(wake[0])
+			syscall.Close(wake[1])
+		}()
+		fds[1] = pollFd{fd: int32(wake[0]), events: pollIn}
+		nfds = 2
+	}
+
+	if cerr := rc.Control(func(fd uintptr) {
+		fds[0] = pollFd{fd: int32(fd), events: pollIn}
+		_, err = poll(fds[:nfds], timeout)
+	}); cerr != nil {
+		return false, cerr
+	}
+	return err == nil && fds[0].revents != 0, err
+}
+
+// startPump starts a goroutine that reads from the underlying reader, so that
+// reads may be waited on with a timeout.
+func (in *Input) startPump() {
+	pump := make(chan inputChunk, 1)
+	go func(r io.Reader, minRead int) {
+		defer close(pump)
+		for {
+			b := make([]byte, minRead)
+			n, err := r.Read(b)
+			pump <- inputChunk{b[:n], err}
+			if err != nil {
+				return
+			}
+		}
+	}(in.r, in.minRead)
+	in.pump = pump
+}
+
+func (in *Input) readPump(ctx context.Context, timeout time.Duration, frm *InputFrame) (int, error) {
+	var chunk inputChunk
+	var ok bool
+	switch {
+	case timeout < 0:
+		select {
+		case chunk, ok = <-in.pump:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	case timeout == 0:
+		select {
+		case chunk, ok = <-in.pump:
+		default:
+			return 0, nil
+		}
+	default:
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case chunk, ok = <-in.pump:
+		case <-timer.C:
+			return 0, nil
+		}
+	}
+	if !ok {
+		return 0, io.EOF
+	}
+	if len(chunk.b) > 0 {
+		in.buf.Grow(len(chunk.b))
+		p := in.buf.Bytes()
+		p = append(p[len(p):], chunk.b...)
+		frm.B = p
+	}
+	return len(chunk.b), chunk.err
+}
+
+// Enter retains the terminal file as the underlying reader, if none was given
+// to NewInput.
 func (in *Input) Enter(term *Term) error {
-	if in.file == nil {
-		in.file = term.File
+	if in.r == nil {
+		in.file, in.r = term.File, term.File
 	}
 	return nil
 }
 
-// Exit clears nonblocking mode from the underlying filehandle.
+// Exit releases the terminal file, if it was retained by Enter.
 func (in *Input) Exit(term *Term) error {
 	if in.file == term.File {
-		err := in.setNonblock(false)
-		in.file = nil
-		return err
+		in.file, in.r = nil, nil
 	}
 	return nil
 }
@@ -260,21 +481,6 @@ func (in *Input) readBuf() []byte {
 	return p
 }
 
-func (in *Input) setNonblock(nonblock bool) error {
-	if nonblock == in.nonblock {
-		return nil
-	}
-	var flags uintptr
-	if nonblock {
-		flags |= syscall.O_NONBLOCK
-	}
-	if _, _, e := syscall.Syscall(syscall.SYS_FCNTL, in.file.Fd(), syscall.F_SETFL, flags); e != 0 {
-		return e
-	}
-	in.nonblock = nonblock
-	return nil
-}
-
 // InputReplay is a session of recorded input.
 type InputReplay []InputFrame
 
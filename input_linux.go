@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+package anansi
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// poll waits up to timeout (forever if negative) for any of the given file
+// descriptors to become ready, returning how many are.
+func poll(fds []pollFd, timeout time.Duration) (int, error) {
+	var ts *syscall.Timespec
+	if timeout >= 0 {
+		t := syscall.NsecToTimespec(int64(timeout))
+		ts = &t
+	}
+	for {
+		n, _, e := syscall.Syscall6(syscall.SYS_PPOLL, uintptr(unsafe.Pointer(&fds[0])), uintptr(len(fds)), uintptr(unsafe.Pointer(ts)), 0, 0, 0)
+		if e == syscall.EINTR {
+			continue
+		}
+		if e != 0 {
+			return 0, e
+		}
+		return int(n), nil
+	}
+}
@@ -0,0 +1,148 @@
+package anansi_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/ansi"
+)
+
+func TestInput_reader(t *testing.T) {
+	t.Run("pipe", logBuf.With(func(t *testing.T) {
+		pr, pw := io.Pipe()
+		in := NewInputReader(pr, 0)
+
+		n, err := in.ReadAny()
+		require.NoError(t, err)
+		assert.Equal(t, 0, n, "expected no input yet")
+
+		n, err = in.ReadTimeout(10 * time.Millisecond)
+		require.NoError(t, err)
+		assert.Equal(t, 0, n, "expected read to time out")
+
+		go pw.Write([]byte("hello"))
+		n, err = in.ReadTimeout(time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, 5, n, "expected bytes read")
+
+		go pw.Write([]byte(" world"))
+		n, err = in.ReadMore()
+		require.NoError(t, err)
+		assert.Equal(t, 6, n, "expected bytes read")
+
+		var s []rune
+		for r, ok := in.DecodeRune(); ok; r, ok = in.DecodeRune() {
+			s = append(s, r)
+		}
+		assert.Equal(t, "hello world", string(s))
+
+		require.NoError(t, pw.Close())
+		n, err = in.ReadMore()
+		assert.Equal(t, 0, n, "expected no bytes at EOF")
+		assert.Equal(t, io.EOF, err)
+	}))
+
+	t.Run("file", logBuf.With(func(t *testing.T) {
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		defer r.Close()
+		defer w.Close()
+
+		flags := func() uintptr {
+			rc, err := r.SyscallConn()
+			require.NoError(t, err)
+			var flags uintptr
+			require.NoError(t, rc.Control(func(fd uintptr) {
+				flags, _, _ = syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFL, 0)
+			}))
+			return flags
+		}
+		before := flags()
+
+		in := NewInput(r, 0)
+		n, err := in.ReadAny()
+		require.NoError(t, err)
+		assert.Equal(t, 0, n, "expected no input yet")
+
+		n, err = in.ReadTimeout(10 * time.Millisecond)
+		require.NoError(t, err)
+		assert.Equal(t, 0, n, "expected read to time out")
+
+		_, err = w.Write([]byte("hello"))
+		require.NoError(t, err)
+		n, err = in.ReadAny()
+		require.NoError(t, err)
+		assert.Equal(t, 5, n, "expected bytes read")
+
+		assert.Equal(t, before, flags(), "expected file status flags to be unchanged")
+	}))
+}
+
+func TestInput_ReadMoreContext(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		open func(t *testing.T) (*Input, io.Closer)
+	}{
+		{"reader", func(t *testing.T) (*Input, io.Closer) {
+			pr, pw := io.Pipe()
+			return NewInputReader(pr, 0), pw
+		}},
+		{"file", func(t *testing.T) (*Input, io.Closer) {
+			r, w, err := os.Pipe()
+			require.NoError(t, err)
+			return NewInput(r, 0), closers{r, w}
+		}},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			in, c := tc.open(t)
+			defer c.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			t0 := time.Now()
+			n, err := in.ReadMoreContext(ctx)
+			assert.Equal(t, 0, n, "expected no bytes read")
+			assert.Equal(t, context.DeadlineExceeded, err, "expected context error")
+			assert.True(t, time.Since(t0) < time.Second, "expected prompt return")
+		}))
+	}
+}
+
+type closers []io.Closer
+
+func (cs closers) Close() (err error) {
+	for _, c := range cs {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func TestInput_Events(t *testing.T) {
+	pr, pw := io.Pipe()
+	in := NewInputReader(pr, 0)
+	go func() {
+		pw.Write([]byte("a\x1b[Ab"))
+		pw.Close()
+	}()
+
+	var evs []InputEvent
+	for ev := range in.Events(context.Background()) {
+		evs = append(evs, ev)
+	}
+	assert.Equal(t, []InputEvent{
+		{R: 'a'},
+		{E: ansi.CSI('A')},
+		{R: 'b'},
+		{Err: io.EOF},
+	}, evs)
+}
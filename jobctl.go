@@ -0,0 +1,175 @@
+package anansi
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+var errJobControlTerm = errors.New("JobControl may only be used under a single terminal")
+
+// JobControl is a Context that handles job control signals while a terminal
+// is active: SIGTSTP (e.g. from Ctrl-Z under cbreak mode, or kill -TSTP),
+// SIGTTIN, and SIGTTOU (e.g. after being backgrounded) are trapped; the
+// terminal context is exited (by Term.Without) to restore the terminal, and
+// then the process group is stopped by SIGSTOP (re-raising the signal
+// wouldn't stop it, since the Go runtime keeps handling a signal once it's
+// been notified).
+// Once continued in the foreground, the terminal context is re-entered, and a
+// value is sent on the Resumed channel, so that the application can fully
+// redraw.
+//
+// Signals are ignored while the process group is orphaned (i.e. when no
+// member of it has a parent, such as a job control shell, in another group of
+// the same session), since nothing would then continue it; the kernel discards
+// such signals for orphaned groups in any case.
+//
+// Signals are handled by a separate goroutine, holding JobControl's lock
+// throughout; applications should hold it (e.g. around each frame update and
+// flush) while using the terminal, so that suspension doesn't interleave
+// with them.
+type JobControl struct {
+	sync.Mutex
+
+	term     *Term
+	sigs     chan os.Signal
+	stop     chan struct{}
+	done     chan struct{}
+	handling int32 // non-zero while the handler goroutine is within Term.Without
+
+	resumedOnce sync.Once
+	resumed     chan struct{}
+}
+
+// Resumed returns a channel that receives a value after the terminal context
+// is re-entered when the process is continued.
+func (jc *JobControl) Resumed() <-chan struct{} {
+	jc.resumedOnce.Do(func() {
+		jc.resumed = make(chan struct{}, 1)
+	})
+	return jc.resumed
+}
+
+// Enter starts handling job control signals for the given terminal.
+func (jc *JobControl) Enter(term *Term) error {
+	if atomic.LoadInt32(&jc.handling) != 0 {
+		return nil
+	}
+	if jc.term != nil {
+		return errJobControlTerm
+	}
+	jc.Resumed()
+	jc.term = term
+	jc.sigs = make(chan os.Signal, 1)
+	jc.stop = make(chan struct{})
+	jc.done = make(chan struct{})
+	signal.Notify(jc.sigs, syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU)
+	go jc.run(term, jc.sigs, jc.stop, jc.done)
+	return nil
+}
+
+// Exit stops handling job control signals, restoring their default actions.
+func (jc *JobControl) Exit(term *Term) error {
+	if atomic.LoadInt32(&jc.handling) != 0 || jc.term != term {
+		return nil
+	}
+	close(jc.stop)
+	<-jc.done
+	signal.Stop(jc.sigs)
+	jc.term, jc.sigs, jc.stop, jc.done = nil, nil, nil, nil
+	return nil
+}
+
+func (jc *JobControl) run(term *Term, sigs chan os.Signal, stop, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case <-stop:
+			return
+		case sig := <-sigs:
+			if err := jc.handle(term, sigs); err != nil {
+				log.Printf("job control: failed to handle %v: %v", sig, err)
+			}
+		}
+	}
+}
+
+func (jc *JobControl) handle(term *Term, sigs chan os.Signal) error {
+	jc.Lock()
+	defer jc.Unlock()
+	atomic.StoreInt32(&jc.handling, 1)
+	defer atomic.StoreInt32(&jc.handling, 0)
+
+	if isOrphaned() {
+		return nil
+	}
+	fg, err := isForeground(term.File)
+	if err != nil {
+		return err
+	}
+	if fg {
+		err = term.Without(func(*Term) error { return jc.suspend(term, sigs) })
+	} else if err = jc.suspend(term, sigs); err == nil {
+		// the terminal can't be restored while in the background, so stop as
+		// is, and then re-enter to re-apply its context once resumed
+		err = term.Without(func(*Term) error { return nil })
+	}
+	if err == nil {
+		select {
+		case jc.resumed <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+// suspend stops the process, and then waits until it has been continued in
+// the foreground.
+func (jc *JobControl) suspend(term *Term, sigs chan os.Signal) error {
+	cont := make(chan os.Signal, 1)
+	signal.Notify(cont, syscall.SIGCONT)
+	defer signal.Stop(cont)
+
+	// notifications are reset while suspended, so that signals due to any
+	// further terminal use while in the background don't queue another
+	signal.Reset(syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU)
+	defer signal.Notify(sigs, syscall.SIGTSTP, syscall.SIGTTIN, syscall.SIGTTOU)
+
+	if err := syscall.Kill(0, syscall.SIGSTOP); err != nil {
+		return err
+	}
+	for {
+		<-cont
+		if fg, err := isForeground(term.File); err != nil || fg {
+			return err
+		}
+	}
+}
+
+// leaderOrphaned approximates whether the calling process's group is
+// orphaned, for when its members can't be enumerated: it's taken to be
+// orphaned unless the session's leader (e.g. a job control shell) is still
+// running outside of it.
+func leaderOrphaned() bool {
+	sid, _, e := syscall.RawSyscall(syscall.SYS_GETSID, 0, 0, 0)
+	if e != 0 || int(sid) == syscall.Getpgrp() {
+		return true
+	}
+	err := syscall.Kill(int(sid), 0)
+	return err != nil && err != syscall.EPERM
+}
+
+// isForeground returns true if the calling process is in the foreground
+// process group of the given terminal.
+func isForeground(f *os.File) (bool, error) {
+	var pgrp int32
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp))); e != 0 {
+		return false, e
+	}
+	return int(pgrp) == syscall.Getpgrp(), nil
+}
@@ -0,0 +1,9 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package anansi
+
+// isOrphaned returns true if the calling process's group is orphaned; since
+// other processes can't be portably enumerated here, this is approximated by
+// whether the session leader is still running outside of the group.
+func isOrphaned() bool { return leaderOrphaned() }
@@ -0,0 +1,92 @@
+//go:build linux
+// +build linux
+
+package anansi
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// isOrphaned returns true if the calling process's group is orphaned: that is,
+// if no member of it has a parent in another group of the same session, as
+// determined by scanning /proc.
+func isOrphaned() bool {
+	procs, err := readProcStats()
+	if err != nil {
+		return leaderOrphaned()
+	}
+	pgrp := syscall.Getpgrp()
+	self, ok := procs[syscall.Getpid()]
+	if !ok {
+		return leaderOrphaned()
+	}
+	for _, st := range procs {
+		if st.pgrp != pgrp {
+			continue
+		}
+		if pst, ok := procs[st.ppid]; ok && pst.pgrp != pgrp && pst.sid == self.sid {
+			return false
+		}
+	}
+	return true
+}
+
+type procStat struct{ ppid, pgrp, sid int }
+
+// readProcStats reads the parent, group, and session of every process listed
+// under /proc; any that exit while doing so are skipped.
+func readProcStats() (map[int]procStat, error) {
+	f, err := os.Open("/proc")
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	procs := make(map[int]procStat, len(names))
+	for _, name := range names {
+		pid, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		buf, err := ioutil.ReadFile("/proc/" + name + "/stat")
+		if err != nil {
+			continue
+		}
+		if st, ok := parseProcStat(buf); ok {
+			procs[pid] = st
+		}
+	}
+	return procs, nil
+}
+
+// parseProcStat parses the ppid, pgrp, and session fields that follow the
+// state field in /proc/PID/stat; the preceding comm field may itself contain
+// spaces and parentheses, so parsing starts after its last closing paren.
+func parseProcStat(buf []byte) (st procStat, ok bool) {
+	i := bytes.LastIndexByte(buf, ')')
+	if i < 0 {
+		return st, false
+	}
+	fields := bytes.Fields(buf[i+1:])
+	if len(fields) < 4 {
+		return st, false
+	}
+	var err error
+	if st.ppid, err = strconv.Atoi(string(fields[1])); err != nil {
+		return st, false
+	}
+	if st.pgrp, err = strconv.Atoi(string(fields[2])); err != nil {
+		return st, false
+	}
+	if st.sid, err = strconv.Atoi(string(fields[3])); err != nil {
+		return st, false
+	}
+	return st, true
+}
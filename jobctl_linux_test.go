@@ -0,0 +1,211 @@
+package anansi_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/pty"
+)
+
+func TestJobControl(t *testing.T) {
+	switch os.Getenv("ANANSI_TEST_JOBCONTROL") {
+	case "job":
+		jobControlHelper()
+		return
+	case "wrap":
+		jobControlWrapper("job")
+		return
+	case "shell":
+		jobControlShell("job")
+		return
+	case "shell-wrap":
+		jobControlShell("wrap")
+		return
+	}
+
+	stopAndContinue := func(t *testing.T, cmd *exec.Cmd, master *os.File, expect func(string), canonical func(bool)) {
+		// the job is run under a job control shell, which reports when it
+		// stops, and continues it in the foreground after a line of input
+		expect("ready")
+		canonical(false)
+		_, err := master.Write([]byte("z"))
+		require.NoError(t, err)
+		expect("stopped")
+		canonical(true)
+		_, err = master.Write([]byte("\n"))
+		require.NoError(t, err)
+		expect("resumed")
+		canonical(false)
+	}
+
+	for _, tc := range []struct {
+		name string
+		run  string
+		test func(t *testing.T, cmd *exec.Cmd, master *os.File, expect func(string), canonical func(bool))
+	}{
+		{"stop and continue", "shell", stopAndContinue},
+
+		// the job's parent shares its group, but the shell beyond keeps it
+		// from being orphaned; both must be stopped and continued
+		{"stop and continue wrapped", "shell-wrap", stopAndContinue},
+
+		{"orphaned", "job", func(t *testing.T, cmd *exec.Cmd, master *os.File, expect func(string), canonical func(bool)) {
+			// the job leads its own session, so can't be stopped; the
+			// signal must be ignored, rather than waiting to be continued
+			expect("ready")
+			require.NoError(t, cmd.Process.Signal(syscall.SIGTSTP))
+			time.Sleep(50 * time.Millisecond)
+			canonical(false)
+		}},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			pt, err := pty.Open()
+			require.NoError(t, err)
+			defer pt.Close()
+
+			cmd := jobControlCommand(tc.run)
+			require.NoError(t, pt.Start(cmd))
+			defer cmd.Process.Kill()
+
+			var out bytes.Buffer // NOTE interspersed with recording time marks
+			in := NewInput(pt.Master, 0)
+			in.SetRecording(&out)
+			expect := func(s string) {
+				deadline := time.Now().Add(5 * time.Second)
+				for !bytes.Contains(out.Bytes(), []byte(s)) {
+					require.True(t, time.Now().Before(deadline), "timed out waiting for %q, got %q", s, out.Bytes())
+					_, err := in.ReadTimeout(10 * time.Millisecond)
+					require.NoError(t, err)
+				}
+			}
+			canonical := func(want bool) {
+				deadline := time.Now().Add(5 * time.Second)
+				for getTermios(t, pt.Slave).Lflag&syscall.ICANON != 0 != want {
+					require.True(t, time.Now().Before(deadline), "timed out waiting for canonical mode %v", want)
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+
+			tc.test(t, cmd, pt.Master, expect, canonical)
+
+			_, err = pt.Master.Write([]byte("q"))
+			require.NoError(t, err)
+			expect("bye")
+			assert.NoError(t, cmd.Wait(), "output: %q", out.Bytes())
+		}))
+	}
+}
+
+// jobControlCommand returns a command that re-runs TestJobControl as the
+// given helper.
+func jobControlCommand(run string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestJobControl$")
+	cmd.Env = append(os.Environ(), "ANANSI_TEST_JOBCONTROL="+run)
+	return cmd
+}
+
+// jobControlHelper runs a raw mode program under JobControl, which stops
+// itself on "z", and exits on "q".
+func jobControlHelper() {
+	var jc JobControl
+	term := NewTerm(os.Stdin, &jc)
+	if err := term.SetRaw(true); err != nil {
+		panic(err)
+	}
+	if err := term.With(func(term *Term) error {
+		jc.Lock()
+		_, _ = term.WriteString("ready\r\n")
+		jc.Unlock()
+		for {
+			var b [1]byte
+			if _, err := term.Read(b[:]); err != nil {
+				return err
+			}
+			switch b[0] {
+			case 'z':
+				if err := syscall.Kill(syscall.Getpid(), syscall.SIGTSTP); err != nil {
+					return err
+				}
+				<-jc.Resumed()
+				jc.Lock()
+				_, _ = term.WriteString("resumed\r\n")
+				jc.Unlock()
+			case 'q':
+				jc.Lock()
+				_, _ = term.WriteString("bye\r\n")
+				jc.Unlock()
+				return nil
+			}
+		}
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// jobControlWrapper runs the given helper within its own process group, as
+// (e.g.) go run or make do.
+func jobControlWrapper(run string) {
+	cmd := jobControlCommand(run)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if err, ok := err.(*exec.ExitError); ok {
+			os.Exit(err.ExitCode())
+		}
+		panic(err)
+	}
+}
+
+// jobControlShell runs the given helper in the foreground as a job; whenever
+// it stops, the shell takes the foreground, reports it, and waits for a line
+// of input before continuing it in the foreground.
+func jobControlShell(run string) {
+	signal.Ignore(syscall.SIGTTOU)
+	cmd := jobControlCommand(run)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Foreground: true, Ctty: 0}
+	if err := cmd.Start(); err != nil {
+		panic(err)
+	}
+	pid := cmd.Process.Pid
+	lines := bufio.NewReader(os.Stdin)
+	for {
+		var ws syscall.WaitStatus
+		if _, err := syscall.Wait4(pid, &ws, syscall.WUNTRACED, nil); err != nil {
+			panic(err)
+		}
+		if !ws.Stopped() {
+			if code := ws.ExitStatus(); code != 0 {
+				os.Exit(code)
+			}
+			return
+		}
+		setForeground(syscall.Getpgrp())
+		fmt.Printf("stopped by %v\r\n", ws.StopSignal())
+		if _, err := lines.ReadString('\n'); err != nil {
+			panic(err)
+		}
+		setForeground(pid)
+		if err := syscall.Kill(-pid, syscall.SIGCONT); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func setForeground(pgrp int) {
+	id := int32(pgrp)
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, 0, syscall.TIOCSPGRP, uintptr(unsafe.Pointer(&id))); e != 0 {
+		panic(e)
+	}
+}
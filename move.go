@@ -0,0 +1,263 @@
+package anansi
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/jcorbin/anansi/ansi"
+)
+
+// MoveTo writes the cheapest sequence of bytes that will move the cursor to
+// the given screen point into buf, returning the number of bytes written.
+//
+// Candidate plans combine absolute (CUP, CHA, VPA), relative (CUU, CUD, CUF,
+// CUB, HPR, VPR), and C0 control (CR, LF, BS, HT) movements. Additionally, if
+// the known grid is non-empty, any cells that lie before pt in its row may be
+// re-written (when their attributes match the current ones) rather than moved
+// over. The known grid must therefore reflect the terminal's contents for such
+// cells; this is the case, for example, while Grid.Update scans row-by-row.
+//
+// HT plans use the given tab stops, and LF plans are only used if LF doesn't
+// imply CR (see Screen.OutputProcessing).
+func (cs *CursorState) MoveTo(pt ansi.Point, buf *ansi.Buffer, known Grid, tabs TabStops) int {
+	var mp movePlanner
+	mp.plan(*cs, pt, known, tabs)
+	cs.X, cs.Y = pt.X, pt.Y
+	cs.wrapNext = false
+	n, _ := buf.Write(mp.best)
+	return n
+}
+
+// movePlanner searches for the shortest byte sequence that moves a cursor.
+type movePlanner struct {
+	cs    CursorState
+	pt    ansi.Point
+	known Grid
+	tabs  TabStops
+
+	best    []byte
+	bestBuf [32]byte
+	candBuf [32]byte
+}
+
+func (mp *movePlanner) plan(cs CursorState, pt ansi.Point, known Grid, tabs TabStops) {
+	mp.cs, mp.pt, mp.known, mp.tabs = cs, pt, known, tabs
+	mp.best = mp.bestBuf[:0]
+	p := mp.candBuf[:0]
+
+	if !cs.Point.Valid() {
+		mp.best = appendCUP(mp.best, pt)
+		return
+	}
+	if cs.Point == pt {
+		if cs.wrapNext {
+			// staying put must still cancel the pending wrap
+			mp.best = appendNum(mp.best, ansi.CHA, pt.X)
+		}
+		return
+	}
+
+	mp.best = appendCUP(mp.best, pt)
+	mp.vertical(append(p, '\r'), 1)
+	mp.vertical(p, cs.X)
+}
+
+// consider replaces the best plan so far if p is shorter.
+func (mp *movePlanner) consider(p []byte) {
+	if len(p) < len(mp.best) {
+		mp.best = append(mp.best[:0], p...)
+	}
+}
+
+// vertical considers plans that move from the current row to the target row,
+// each followed by any horizontal movement from column x.
+func (mp *movePlanner) vertical(p []byte, x int) {
+	dy := mp.pt.Y - mp.cs.Y
+	switch {
+	case dy == 0:
+		mp.horizontal(p, x)
+		return
+	case dy > 0:
+		mp.horizontal(appendNum(p, ansi.CUD, dy), x)
+		mp.horizontal(appendNum(p, ansi.VPR, dy), x)
+		if !mp.cs.lfCR && len(p)+dy < len(mp.best) {
+			mp.horizontal(appendRepeat(p, '\n', dy), x)
+		}
+	case dy < 0:
+		mp.horizontal(appendNum(p, ansi.CUU, -dy), x)
+	}
+	mp.horizontal(appendNum(p, ansi.VPA, mp.pt.Y), x)
+}
+
+// horizontal considers plans that move from column x to the target column,
+// having already written the bytes in p.
+func (mp *movePlanner) horizontal(p []byte, x int) {
+	tx := mp.pt.X
+	if x == tx {
+		mp.consider(p)
+		return
+	}
+	if len(p) >= len(mp.best) {
+		return
+	}
+	if tx == 1 {
+		mp.consider(append(p, '\r'))
+	}
+
+	// any relative movement is unreliable after writing into the last
+	// column, since terminals differ in how they handle the pending wrap
+	if w := mp.known.Size.X; !mp.cs.wrapNext && (w <= 0 || x <= w) {
+		if dx := tx - x; dx > 0 {
+			mp.forward(p, x)
+		} else {
+			mp.consider(appendNum(p, ansi.CUB, -dx))
+			if len(p)-dx < len(mp.best) {
+				mp.consider(appendRepeat(p, '\b', -dx))
+			}
+			if x != 1 {
+				mp.forward(append(p, '\r'), 1)
+			}
+		}
+	}
+
+	mp.consider(appendNum(p, ansi.CHA, tx))
+}
+
+// forward considers plans that move forward from column x to the target
+// column, having already written the bytes in p.
+func (mp *movePlanner) forward(p []byte, x int) {
+	tx := mp.pt.X
+	if x == tx {
+		mp.consider(p)
+		return
+	}
+	mp.consider(appendNum(p, ansi.CUF, tx-x))
+	mp.consider(appendNum(p, ansi.HPR, tx-x))
+	mp.rewrite(p, x)
+	if s := mp.tabs.Next(x, 0); s > x && s <= tx {
+		for ; s > x && s <= tx; s = mp.tabs.Next(s, 0) {
+			p, x = append(p, '\t'), s
+		}
+		if x == tx {
+			mp.consider(p)
+		} else {
+			mp.consider(appendNum(p, ansi.CUF, tx-x))
+			mp.rewrite(p, x)
+		}
+	}
+}
+
+// rewrite considers a plan that writes the known cells from column x up to
+// the target column, having already written the bytes in p.
+func (mp *movePlanner) rewrite(p []byte, x int) {
+	g, y, tx := mp.known, mp.pt.Y, mp.pt.X
+	if !mp.cs.attrKnown || g.Size.X == 0 || y > g.Size.Y || tx-1 > g.Size.X {
+		return
+	}
+	i := (y-1)*g.Size.X + x - 1
+	for ; x < tx; x, i = x+1, i+1 {
+		if len(p) >= len(mp.best) {
+			return
+		}
+		r, a := g.Rune[i], g.Attr[i]
+		if r == 0 {
+			r, a = ' ', 0
+		}
+		if a != mp.cs.Attr || !unicode.IsGraphic(r) {
+			return
+		}
+		if r < utf8.RuneSelf {
+			p = append(p, byte(r))
+		} else {
+			var tmp [utf8.UTFMax]byte
+			n := utf8.EncodeRune(tmp[:], r)
+			p = append(p, tmp[:n]...)
+		}
+	}
+	mp.consider(p)
+}
+
+// moveSeq returns the shortest single control sequence that moves from the
+// cursor point to the given one.
+func (cs CursorState) moveSeq(pt ansi.Point) ansi.Seq {
+	if !cs.Point.Valid() {
+		return cupSeq(pt)
+	}
+	if cs.Point == pt {
+		if cs.wrapNext {
+			return numSeq(ansi.CHA, pt.X)
+		}
+		return ansi.Seq{}
+	}
+
+	best := cupSeq(pt)
+	bestSize := seqSize(best)
+	consider := func(seq ansi.Seq) {
+		if n := seqSize(seq); n < bestSize {
+			best, bestSize = seq, n
+		}
+	}
+
+	dx, dy := pt.X-cs.X, pt.Y-cs.Y
+	switch {
+	case dy == 0:
+		if pt.X == 1 {
+			consider(ansi.Escape('\r').With())
+		}
+		if cs.wrapNext {
+			// relative movement is unreliable during a pending wrap
+		} else if dx > 0 {
+			consider(numSeq(ansi.CUF, dx))
+		} else {
+			consider(numSeq(ansi.CUB, -dx))
+		}
+		consider(numSeq(ansi.CHA, pt.X))
+	case dx == 0:
+		if dy > 0 {
+			consider(numSeq(ansi.CUD, dy))
+		} else {
+			consider(numSeq(ansi.CUU, -dy))
+		}
+		consider(numSeq(ansi.VPA, pt.Y))
+	case pt.X == 1 && dy > 0 && dy < bestSize:
+		// CR followed by LF(s)
+		lfs := make([]byte, dy)
+		for i := range lfs {
+			lfs[i] = '\n'
+		}
+		consider(ansi.Escape('\r').With(lfs...))
+	}
+	return best
+}
+
+func seqSize(seq ansi.Seq) int {
+	var tmp [32]byte
+	return len(seq.AppendTo(tmp[:0]))
+}
+
+func cupSeq(pt ansi.Point) ansi.Seq {
+	if pt == ansi.Pt(1, 1) {
+		return ansi.CUP.With()
+	}
+	return ansi.CUP.WithPoint(pt)
+}
+
+// numSeq returns a sequence with a single numeric argument, omitting it if it
+// is the default value 1.
+func numSeq(id ansi.Escape, n int) ansi.Seq {
+	if n == 1 {
+		return id.With()
+	}
+	return id.WithInts(n)
+}
+
+func appendCUP(p []byte, pt ansi.Point) []byte { return cupSeq(pt).AppendTo(p) }
+
+func appendNum(p []byte, id ansi.Escape, n int) []byte { return numSeq(id, n).AppendTo(p) }
+
+func appendRepeat(p []byte, b byte, n int) []byte {
+	for i := 0; i < n; i++ {
+		p = append(p, b)
+	}
+	return p
+}
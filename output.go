@@ -6,6 +6,8 @@ import (
 	"os"
 	"syscall"
 	"time"
+
+	"github.com/jcorbin/anansi/ansi"
 )
 
 // NewOutput creates a new terminal output writer around the given file; if
@@ -21,8 +23,39 @@ func NewOutput(f *os.File) *Output {
 // goroutines, such users need to layer a lock around an Output.
 type Output struct {
 	Flushed int
+
+	// Written, Skipped, and Deferred count the total bytes written by
+	// Flush, and the number of Flush calls skipped due to Budget or deferred
+	// due to NoStall.
+	Written  int64
+	Skipped  int
+	Deferred int
+
+	// Budget, if positive, limits output to that many bytes per second on
+	// average, allowing bursts of up to a second's worth; Flush is skipped
+	// (without calling its io.WriterTo) while the budget is overdrawn.
+	Budget int
+
+	// NoStall causes Flush to write without blocking (only writing while
+	// polling reports the file writable, rather than changing its flags),
+	// leaving any output that would block pending in its io.WriterTo, rather
+	// than stalling; the next Flush (which should be given the same
+	// io.WriterTo) then resumes it, so that (e.g.) a Screen only builds its
+	// next update once the last has been fully written.
+	NoStall bool
+
+	// Sync, if not ansi.SyncNone, brackets the output written by each Flush
+	// so that the terminal draws it all at once; it should only be set once
+	// the terminal is known to support the protocol, e.g. by probing with
+	// ansi.AppendSyncProbe.
+	Sync ansi.SyncProtocol
+
 	blocks  []time.Duration
 	file    *os.File
+	frame   syncFrame
+	pending io.WriterTo // output left pending by NoStall
+	credit  float64     // bytes available under Budget
+	refill  time.Time   // last time credit was refilled
 }
 
 // TrackStalls allocates a buffer for tracking stall times; otherwise Stalls()
@@ -80,20 +113,76 @@ func (out *Output) Exit(term *Term) error {
 }
 
 // Flush calls the given io.Writerto on any active file handle. If EWOULDBLOCK
-// occurs, it transitions the file into blocking mode, and restarts the write.
+// occurs (e.g. the file was made non-blocking elsewhere), it transitions the
+// file into blocking mode, and restarts the write; any Sync frame markers are
+// therefore always written around it as a whole.
+//
+// Flush does nothing if the Budget is overdrawn (unless output is pending), or
+// if NoStall is set and the write would block.
 func (out *Output) Flush(wer io.WriterTo) error {
 	if out.file == nil {
 		return nil
 	}
 	out.Flushed = 0
-	n, err := wer.WriteTo(out.file)
-	out.Flushed += int(n)
+	if out.pending == nil {
+		if !out.withinBudget(time.Now()) {
+			out.Skipped++
+			return nil
+		}
+		if out.Sync != ansi.SyncNone {
+			out.frame.reset(out.Sync, wer)
+			wer = &out.frame
+		}
+	} else if out.pending == &out.frame {
+		wer = &out.frame // resume any frame markers
+	}
+	var n int64
+	var err error
+	if out.NoStall {
+		n, err = out.nonblockingWrite(wer)
+	} else {
+		n, err = wer.WriteTo(out.file)
+	}
+	out.wrote(n)
+	out.pending = nil
 	if isEWouldBlock(err) {
+		if out.NoStall {
+			out.pending = wer
+			out.Deferred++
+			return nil
+		}
 		return out.blockingFlush(wer)
 	}
 	return err
 }
 
+// Pending returns true if output has been left pending by NoStall.
+func (out *Output) Pending() bool { return out.pending != nil }
+
+// withinBudget refills credit under any Budget, returning false if it's
+// overdrawn.
+func (out *Output) withinBudget(now time.Time) bool {
+	if out.Budget <= 0 {
+		return true
+	}
+	max := float64(out.Budget)
+	if out.refill.IsZero() {
+		out.credit = max
+	} else if out.credit += now.Sub(out.refill).Seconds() * max; out.credit > max {
+		out.credit = max
+	}
+	out.refill = now
+	return out.credit > 0
+}
+
+func (out *Output) wrote(n int64) {
+	out.Flushed += int(n)
+	out.Written += n
+	if out.Budget > 0 {
+		out.credit -= float64(n)
+	}
+}
+
 func (out *Output) blockingFlush(wer io.WriterTo) error {
 	if out.blocks != nil {
 		defer func(t0 time.Time) {
@@ -104,21 +193,26 @@ func (out *Output) blockingFlush(wer io.WriterTo) error {
 		}(time.Now())
 	}
 
-	const mask = syscall.O_NONBLOCK | syscall.O_ASYNC
-
+	// only the non-blocking flag is cleared (and then restored), leaving any
+	// other file status flags, as shared with other users of the file, alone
 	flags, _, e := syscall.Syscall(syscall.SYS_FCNTL, out.file.Fd(), syscall.F_GETFL, 0)
 	if e != 0 {
 		return e
 	}
+	if flags&syscall.O_NONBLOCK == 0 {
+		n, err := wer.WriteTo(out.file)
+		out.wrote(n)
+		return err
+	}
 
-	if _, _, e = syscall.Syscall(syscall.SYS_FCNTL, out.file.Fd(), syscall.F_SETFL, 0); e != 0 {
+	if _, _, e = syscall.Syscall(syscall.SYS_FCNTL, out.file.Fd(), syscall.F_SETFL, flags&^syscall.O_NONBLOCK); e != 0 {
 		return e
 	}
 
 	n, err := wer.WriteTo(out.file)
-	out.Flushed += int(n)
+	out.wrote(n)
 
-	if _, _, e = syscall.Syscall(syscall.SYS_FCNTL, out.file.Fd(), syscall.F_SETFL, flags&mask); e != 0 {
+	if _, _, e = syscall.Syscall(syscall.SYS_FCNTL, out.file.Fd(), syscall.F_SETFL, flags); e != 0 {
 		if err == nil {
 			err = e
 		}
@@ -126,3 +220,121 @@ func (out *Output) blockingFlush(wer io.WriterTo) error {
 
 	return err
 }
+
+// nonblockingWrite writes to the file without blocking, leaving its status
+// flags (which are shared with any other users of the file, e.g. Input)
+// alone. Writes bypass the os.File, since it would wait for a pollable file to
+// become writable.
+func (out *Output) nonblockingWrite(wer io.WriterTo) (int64, error) {
+	rc, err := out.file.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	return wer.WriteTo(pollWriter{rc})
+}
+
+// pollWriteChunk is the most that pollWriter writes after each poll; POSIX only
+// guarantees that a writable pipe has room for PIPE_BUF (at least 512) bytes,
+// so larger writes may block.
+const pollWriteChunk = 512
+
+// pollWriter writes directly to a file descriptor in chunks, for as long as
+// polling it (with a zero timeout) reports it writable, returning EWOULDBLOCK
+// once it isn't.
+type pollWriter struct{ rc syscall.RawConn }
+
+func (pw pollWriter) Write(p []byte) (n int, err error) {
+	if cerr := pw.rc.Control(func(fd uintptr) {
+		fds := [1]pollFd{{fd: int32(fd), events: pollOut}}
+		for n < len(p) && err == nil {
+			var ready int
+			if ready, err = poll(fds[:], 0); err != nil {
+				break
+			}
+			if ready == 0 {
+				err = syscall.EWOULDBLOCK
+				break
+			}
+			chunk := p[n:]
+			if len(chunk) > pollWriteChunk {
+				chunk = chunk[:pollWriteChunk]
+			}
+			var m int
+			m, err = syscall.Write(int(fd), chunk)
+			if m > 0 {
+				n += m
+			}
+			if err == syscall.EINTR {
+				err = nil
+			}
+		}
+	}); cerr != nil && err == nil {
+		err = cerr
+	}
+	return n, err
+}
+
+// syncFrame brackets the output of an io.WriterTo with synchronized output
+// markers; it may be resumed by calling WriteTo again after an error, which
+// picks up wherever the prior attempt stopped.
+type syncFrame struct {
+	wer     io.WriterTo
+	mark    []byte // begin and end markers
+	begin   []byte // unwritten part of the begin marker
+	end     []byte // unwritten part of the end marker
+	started bool
+}
+
+func (fr *syncFrame) reset(sp ansi.SyncProtocol, wer io.WriterTo) {
+	fr.wer = wer
+	fr.mark = sp.AppendBegin(fr.mark[:0])
+	i := len(fr.mark)
+	fr.mark = sp.AppendEnd(fr.mark)
+	fr.begin, fr.end = fr.mark[:i], fr.mark[i:]
+	fr.started = false
+}
+
+// WriteTo writes any remaining begin marker, then calls the wrapped WriterTo
+// (which may write nothing, in which case no end marker is needed), and
+// finally writes any remaining end marker.
+func (fr *syncFrame) WriteTo(w io.Writer) (n int64, err error) {
+	if fr.wer != nil {
+		sw := syncWriter{fr, w}
+		n, err = fr.wer.WriteTo(&sw)
+		if err != nil {
+			return n, err
+		}
+		fr.wer = nil
+	}
+	if fr.started {
+		for len(fr.end) > 0 {
+			m, err := w.Write(fr.end)
+			n += int64(m)
+			if fr.end = fr.end[m:]; err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// syncWriter writes any remaining begin marker of its frame before the first
+// non-empty write.
+type syncWriter struct {
+	fr *syncFrame
+	w  io.Writer
+}
+
+func (sw *syncWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	sw.fr.started = true
+	for len(sw.fr.begin) > 0 {
+		m, err := sw.w.Write(sw.fr.begin)
+		if sw.fr.begin = sw.fr.begin[m:]; err != nil {
+			return 0, err
+		}
+	}
+	return sw.w.Write(p)
+}
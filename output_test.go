@@ -0,0 +1,222 @@
+package anansi_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/ansi"
+	"github.com/jcorbin/anansi/pty"
+	anansitest "github.com/jcorbin/anansi/test"
+)
+
+func TestOutput_sync(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		sync  ansi.SyncProtocol
+		frame string
+	}{
+		{"none", ansi.SyncNone, "hello"},
+		{"mode", ansi.SyncMode, "hello"},
+		{"dcs", ansi.SyncDCS, "hello"},
+		{"empty", ansi.SyncMode, ""},
+		{"stalled", ansi.SyncMode, strings.Repeat("0123456789abcdef", 64*1024)},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			// use a pipe whose write end isn't managed by the runtime poller,
+			// so that EWOULDBLOCK surfaces once it fills
+			var fds [2]int
+			require.NoError(t, syscall.Pipe(fds[:]))
+			r, w := os.NewFile(uintptr(fds[0]), "r"), os.NewFile(uintptr(fds[1]), "w")
+			defer r.Close()
+			require.NoError(t, syscall.SetNonblock(fds[1], true))
+
+			// start reading only once the writer has had time to fill the pipe
+			got := make(chan []byte)
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				b, _ := ioutil.ReadAll(r)
+				got <- b
+			}()
+
+			out := NewOutput(w)
+			out.TrackStalls(1)
+			out.Sync = tc.sync
+			var cur Cursor
+			cur.WriteString(tc.frame)
+			require.NoError(t, out.Flush(&cur))
+			require.NoError(t, out.Flush(&cur), "expected empty flush")
+			w.Close()
+
+			var expect bytes.Buffer
+			if tc.frame != "" {
+				expect.Write(tc.sync.AppendBegin(nil))
+				expect.WriteString(tc.frame)
+				expect.Write(tc.sync.AppendEnd(nil))
+			}
+			b := <-got
+			assert.Equal(t, expect.Len(), len(b), "expected output length")
+			assert.True(t, bytes.Equal(expect.Bytes(), b), "expected output")
+			if tc.name == "stalled" {
+				assert.Len(t, out.Stalls(false), 1, "expected a stall")
+			}
+		}))
+	}
+}
+
+func TestOutput_budget(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+	go ioutil.ReadAll(r)
+
+	out := NewOutput(w)
+	out.Budget = 100
+	var cur Cursor
+	cur.WriteString(strings.Repeat("x", 1000))
+	require.NoError(t, out.Flush(&cur))
+	assert.Equal(t, int64(1000), out.Written, "expected first frame written")
+
+	cur.WriteString("y")
+	require.NoError(t, out.Flush(&cur))
+	assert.Equal(t, int64(1000), out.Written, "expected second frame skipped")
+	assert.Equal(t, 1, out.Skipped, "expected skip count")
+}
+
+func TestOutput_noStall(t *testing.T) {
+	var fds [2]int
+	require.NoError(t, syscall.Pipe(fds[:]))
+	r, w := os.NewFile(uintptr(fds[0]), "r"), os.NewFile(uintptr(fds[1]), "w")
+	defer r.Close()
+	require.NoError(t, syscall.SetNonblock(fds[1], true))
+
+	size := image.Pt(200, 100)
+	draw := func(sc *Screen, seed int64, top int) {
+		rng := rand.New(rand.NewSource(seed))
+		sc.Reset()
+		for i := top * size.X; i < len(sc.Grid.Rune); i++ {
+			sc.Grid.Rune[i] = rune('a' + rng.Intn(26))
+			sc.Grid.Attr[i] = ansi.RGB(uint8(rng.Intn(256)), uint8(rng.Intn(256)), 0).FG()
+		}
+	}
+
+	var sc Screen
+	sc.Resize(size)
+	out := NewOutput(w)
+	out.NoStall = true
+
+	// an initial blank frame fits in the pipe
+	draw(&sc, 0, size.Y)
+	require.NoError(t, out.Flush(&sc))
+	require.False(t, out.Pending(), "expected blank frame to be written")
+
+	// nothing reads yet, so the next frame fills the pipe, and is left pending
+	draw(&sc, 1, 0)
+	require.NoError(t, out.Flush(&sc))
+	require.True(t, out.Pending(), "expected output to be pending")
+	assert.Equal(t, 1, out.Deferred, "expected a deferred frame")
+
+	// the next frame reverts the top half, which was already partially
+	// written, so must be diffed against the pending frame, not the blank one
+	draw(&sc, 2, size.Y/2)
+	expect := anansitest.GridLines(sc.Grid, ' ')
+
+	got := make(chan []byte)
+	go func() {
+		b, _ := ioutil.ReadAll(r)
+		got <- b
+	}()
+	for flushes := 0; flushes < 2; {
+		require.NoError(t, out.Flush(&sc))
+		if !out.Pending() {
+			flushes++
+		}
+	}
+	w.Close()
+
+	var term Terminal
+	term.Resize(size)
+	term.Write(<-got)
+	assert.Equal(t, expect, anansitest.GridLines(term.Grid, ' '), "expected terminal to show the last frame")
+}
+
+func TestOutput_noStall_pty(t *testing.T) {
+	pt, err := pty.Open()
+	if err != nil {
+		t.Skipf("unable to open pty: %v", err)
+	}
+	defer pt.Close()
+
+	in := NewInput(nil, 0)
+	out := NewOutput(nil)
+	out.NoStall = true
+	term := NewTerm(pt.Slave, in, out)
+	require.NoError(t, term.With(func(term *Term) error {
+		require.NoError(t, term.SetRaw(true))
+		ctx, cancel := context.WithCancel(context.Background())
+		events := in.Events(ctx)
+		defer func() {
+			cancel()
+			for range events {
+			}
+		}()
+
+		// nothing reads the master yet, so a large frame fills the pty, and
+		// is left pending rather than stalling
+		frame := strings.Repeat("0123456789abcdef", 64*1024)
+		var cur Cursor
+		cur.WriteString(frame)
+		flushed := make(chan error, 1)
+		go func() { flushed <- out.Flush(&cur) }()
+		select {
+		case err := <-flushed:
+			require.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("expected flush not to stall")
+		}
+		require.True(t, out.Pending(), "expected output to be pending")
+		assert.Equal(t, 1, out.Deferred, "expected a deferred frame")
+
+		// meanwhile, input is still read
+		_, err := pt.Master.Write([]byte("hi"))
+		require.NoError(t, err)
+		for _, r := range "hi" {
+			select {
+			case ev := <-events:
+				require.NoError(t, ev.Err)
+				assert.Equal(t, r, ev.R, "expected input rune")
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for input")
+			}
+		}
+
+		// the rest of the frame is written once the master reads it
+		got := make(chan []byte, 1)
+		go func() {
+			b := make([]byte, len(frame))
+			n, _ := io.ReadFull(pt.Master, b)
+			got <- b[:n]
+		}()
+		deadline := time.Now().Add(5 * time.Second)
+		for out.Pending() && time.Now().Before(deadline) {
+			require.NoError(t, out.Flush(&cur))
+			time.Sleep(time.Millisecond)
+		}
+		require.False(t, out.Pending(), "expected output to be written")
+		assert.Equal(t, frame, string(<-got), "expected whole frame")
+		return nil
+	}))
+}
@@ -0,0 +1,123 @@
+package pty
+
+import (
+	"image"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+
+	"github.com/jcorbin/anansi"
+)
+
+// PTY is a pseudo-terminal pair: the Slave end is a terminal device, suitable
+// for a child process (see Start) or for anansi.NewTerm, while the Master
+// end receives everything written to the Slave, and is read as its input;
+// e.g. it may be used with anansi.NewInput and anansi.NewOutput.
+type PTY struct {
+	Master *os.File
+	Slave  *os.File
+}
+
+// Open opens a new pseudo-terminal pair.
+func Open() (*PTY, error) {
+	master, slaveName, err := open()
+	if err != nil {
+		return nil, err
+	}
+	slave, err := os.OpenFile(slaveName, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	return &PTY{Master: master, Slave: slave}, nil
+}
+
+// Close closes both ends of the pair, returning the first error.
+func (pt *PTY) Close() error {
+	err := pt.CloseSlave()
+	if merr := pt.Master.Close(); err == nil {
+		err = merr
+	}
+	return err
+}
+
+// CloseSlave closes the Slave end, if still open; this should be done once a
+// child process has been started on it, so that reading the Master ends
+// (with EOF or EIO) once the child exits.
+func (pt *PTY) CloseSlave() error {
+	if pt.Slave == nil {
+		return nil
+	}
+	err := pt.Slave.Close()
+	pt.Slave = nil
+	return err
+}
+
+// Start starts a command with the Slave as its controlling terminal, as the
+// leader of a new session; its standard input, output, and error default to
+// the Slave if not set.
+func (pt *PTY) Start(cmd *exec.Cmd) error {
+	if pt.Slave == nil {
+		return os.ErrClosed
+	}
+	if cmd.Stdin == nil {
+		cmd.Stdin = pt.Slave
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = pt.Slave
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = pt.Slave
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = pt.childFD(cmd)
+	return cmd.Start()
+}
+
+// childFD returns the file descriptor number that the Slave will have in the
+// child process started for cmd.
+func (pt *PTY) childFD(cmd *exec.Cmd) int {
+	for fd, f := range []interface{}{cmd.Stdin, cmd.Stdout, cmd.Stderr} {
+		if f == pt.Slave {
+			return fd
+		}
+	}
+	for i, f := range cmd.ExtraFiles {
+		if f == pt.Slave {
+			return 3 + i
+		}
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, pt.Slave)
+	return 2 + len(cmd.ExtraFiles)
+}
+
+// SetSize sets the terminal size, and the size of its text area in pixels
+// (which may be zero if unknown); the session running on the Slave is sent
+// SIGWINCH when it changes.
+func (pt *PTY) SetSize(size, pixels image.Point) error {
+	dim := struct {
+		rows    uint16
+		cols    uint16
+		xpixels uint16
+		ypixels uint16
+	}{uint16(size.Y), uint16(size.X), uint16(pixels.X), uint16(pixels.Y)}
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, pt.Master.Fd(), syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&dim))); e != 0 {
+		return e
+	}
+	return nil
+}
+
+// CopySize sets the terminal size to match the given terminal, e.g. after it
+// has been resized.
+func (pt *PTY) CopySize(term *anansi.Term) error {
+	size, pixels, err := term.WindowSize()
+	if err == nil {
+		err = pt.SetSize(size, pixels)
+	}
+	return err
+}
@@ -0,0 +1,39 @@
+//go:build darwin
+// +build darwin
+
+package pty
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+func open() (master *os.File, slaveName string, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	var name [128]byte
+	if err = ioctl(master, syscall.TIOCPTYGRANT, 0); err == nil {
+		if err = ioctl(master, syscall.TIOCPTYUNLK, 0); err == nil {
+			err = ioctl(master, syscall.TIOCPTYGNAME, uintptr(unsafe.Pointer(&name[0])))
+		}
+	}
+	if err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	if i := bytes.IndexByte(name[:], 0); i >= 0 {
+		slaveName = string(name[:i])
+	}
+	return master, slaveName, nil
+}
+
+func ioctl(f *os.File, request, arg uintptr) error {
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, arg); e != 0 {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+//go:build linux
+// +build linux
+
+package pty
+
+import (
+	"os"
+	"strconv"
+	"syscall"
+	"unsafe"
+)
+
+func open() (master *os.File, slaveName string, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	var unlock int32
+	var n uint32
+	if err = ioctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err == nil {
+		err = ioctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n)))
+	}
+	if err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	return master, "/dev/pts/" + strconv.Itoa(int(n)), nil
+}
+
+func ioctl(f *os.File, request, arg uintptr) error {
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, arg); e != 0 {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package pty
+
+import (
+	"errors"
+	"os"
+)
+
+var errUnsupported = errors.New("pseudo-terminals not supported on this platform")
+
+func open() (master *os.File, slaveName string, err error) {
+	return nil, "", errUnsupported
+}
@@ -0,0 +1,52 @@
+package pty_test
+
+import (
+	"bytes"
+	"image"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/pty"
+)
+
+func TestPTY(t *testing.T) {
+	pt, err := pty.Open()
+	if err != nil {
+		t.Skipf("unable to open pty: %v", err)
+	}
+	defer pt.Close()
+
+	require.NoError(t, pt.SetSize(image.Pt(80, 24), image.Pt(640, 384)))
+	term := anansi.NewTerm(pt.Slave)
+	require.NoError(t, term.Attr.Enter(term))
+	size, pixels, err := term.WindowSize()
+	require.NoError(t, err)
+	assert.Equal(t, image.Pt(80, 24), size, "expected size")
+	assert.Equal(t, image.Pt(640, 384), pixels, "expected pixel size")
+	require.NoError(t, term.Attr.Exit(term))
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skipf("no shell available: %v", err)
+	}
+	cmd := exec.Command(sh, "-c", "stty size; tty -s && echo tty")
+	require.NoError(t, pt.Start(cmd))
+	require.NoError(t, pt.CloseSlave())
+
+	var out bytes.Buffer
+	in := anansi.NewInput(pt.Master, 0)
+	for {
+		if _, err := in.ReadMore(); err != nil {
+			break
+		}
+		for r, ok := in.DecodeRune(); ok; r, ok = in.DecodeRune() {
+			out.WriteRune(r)
+		}
+	}
+	require.NoError(t, cmd.Wait())
+	assert.Equal(t, "24 80\r\ntty\r\n", out.String())
+}
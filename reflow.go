@@ -0,0 +1,134 @@
+package anansi
+
+import (
+	"image"
+
+	"github.com/jcorbin/anansi/ansi"
+)
+
+// ResizeMode determines how ScreenState.Resize treats existing screen content.
+type ResizeMode int
+
+// Resize modes
+const (
+	// ResizeReslice just reslices the grid's cell arrays, scrambling any
+	// existing content; this is fine for clients that fully redraw after
+	// every resize (as Screen users typically do), and is the default.
+	ResizeReslice ResizeMode = iota
+
+	// ResizeCrop preserves content row by row, cropping or padding each row
+	// to the new width.
+	ResizeCrop
+
+	// ResizeReflow joins soft-wrapped rows (see Grid.Wrapped) back into
+	// logical lines, and then rewraps them to the new width, keeping the
+	// cursor on the same logical character.
+	ResizeReflow
+)
+
+// gridRow is a row of cells in transit during resizeContent.
+type gridRow struct {
+	rune    []rune
+	attr    []ansi.SGRAttr
+	wrapped bool
+}
+
+// resizeContent resizes the grid while preserving its content by crop or
+// reflow, returning the new cursor point corresponding to pt. If the cursor
+// would no longer fit, rows are scrolled off the top to keep it on the last
+// row, and pushed into sb if it isn't nil. A zero pt is treated as no cursor.
+func (g *Grid) resizeContent(size image.Point, reflow bool, pt ansi.Point, sb *Scrollback) ansi.Point {
+	if size.X < 1 || size.Y < 1 {
+		g.Resize(size)
+		return ansi.Point{}
+	}
+	w, h := g.Size.X, g.Size.Y
+	oldRune := append([]rune(nil), g.Rune...)
+	oldAttr := append([]ansi.SGRAttr(nil), g.Attr...)
+
+	var rows []gridRow
+	cx, cy := 0, -1
+	for y := 0; y < h; y++ {
+		start := y
+		if reflow {
+			for y < h-1 && g.Wrapped(y+1) {
+				y++
+			}
+		}
+		runes := oldRune[start*w : (y+1)*w]
+		attrs := oldAttr[start*w : (y+1)*w]
+		lastWrapped := g.Wrapped(y + 1)
+
+		off := -1
+		if pt.Valid() && start < pt.Y && pt.Y <= y+1 {
+			off = (pt.Y-1-start)*w + pt.X - 1
+		}
+
+		if !reflow {
+			n := w
+			if n > size.X {
+				n = size.X
+			}
+			if off >= 0 {
+				cx, cy = off, len(rows)
+				if cx >= size.X {
+					cx = size.X - 1
+				}
+			}
+			rows = append(rows, gridRow{runes[:n], attrs[:n], lastWrapped})
+			continue
+		}
+
+		n := len(runes)
+		for n > 0 && runes[n-1] == 0 && attrs[n-1] == 0 {
+			n--
+		}
+		if off >= 0 {
+			if n <= off {
+				n = off + 1
+			}
+			cx, cy = off%size.X, len(rows)+off/size.X
+		}
+		for i := 0; i == 0 || i < n; i += size.X {
+			j := i + size.X
+			if j > n {
+				j = n
+			}
+			rows = append(rows, gridRow{runes[i:j], attrs[i:j], j < n || lastWrapped})
+		}
+	}
+
+	drop := 0
+	if cy >= size.Y {
+		drop = cy - size.Y + 1
+	}
+	if sb != nil {
+		if size.X != w {
+			sb.Rewrap(size.X)
+		}
+		for _, row := range rows[:drop] {
+			sb.Push(row.rune, row.attr, row.wrapped)
+		}
+	}
+	rows = rows[drop:]
+
+	g.Resize(size)
+	for i := range g.Rune {
+		g.Rune[i], g.Attr[i] = 0, 0
+	}
+	g.markDirty(0, size.Y)
+	for y := 0; y < size.Y; y++ {
+		wrapped := false
+		if y < len(rows) {
+			copy(g.Rune[y*size.X:], rows[y].rune)
+			copy(g.Attr[y*size.X:], rows[y].attr)
+			wrapped = rows[y].wrapped
+		}
+		g.setWrapped(y, wrapped)
+	}
+
+	if cy < 0 {
+		return ansi.Point{}
+	}
+	return ansi.Pt(cx+1, cy-drop+1)
+}
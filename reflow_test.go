@@ -0,0 +1,103 @@
+package anansi_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/ansi"
+	anansitest "github.com/jcorbin/anansi/test"
+)
+
+func TestScreenState_Resize(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		mode       ResizeMode
+		writes     []string
+		size       image.Point
+		lines      []string
+		cursor     ansi.Point
+		scrollback []string
+	}{
+		{
+			name:   "crop narrower",
+			mode:   ResizeCrop,
+			writes: []string{"0123456789\r\nabcdef"},
+			size:   image.Pt(4, 3),
+			lines:  []string{"0123", "abcd", "    "},
+			cursor: ansi.Pt(4, 2),
+		},
+
+		{
+			name:   "crop wider",
+			mode:   ResizeCrop,
+			writes: []string{"0123\r\nab"},
+			size:   image.Pt(12, 4),
+			lines:  []string{"0123        ", "ab          ", "            ", "            "},
+			cursor: ansi.Pt(3, 2),
+		},
+
+		{
+			name:       "crop shorter",
+			mode:       ResizeCrop,
+			writes:     []string{"1\r\n2\r\n3"},
+			size:       image.Pt(10, 2),
+			lines:      []string{"2         ", "3         "},
+			cursor:     ansi.Pt(2, 2),
+			scrollback: []string{"1"},
+		},
+
+		{
+			name:   "reflow narrower",
+			mode:   ResizeReflow,
+			writes: []string{"0123456\r\nab"},
+			size:   image.Pt(4, 4),
+			lines:  []string{"0123", "456 ", "ab  ", "    "},
+			cursor: ansi.Pt(3, 3),
+		},
+
+		{
+			name:   "reflow wider",
+			mode:   ResizeReflow,
+			writes: []string{"0123456789abc\r\nx"},
+			size:   image.Pt(15, 3),
+			lines:  []string{"0123456789abc  ", "x              ", "               "},
+			cursor: ansi.Pt(2, 2),
+		},
+
+		{
+			name:   "reflow cursor",
+			mode:   ResizeReflow,
+			writes: []string{"0123456789abc\x1b[2D"},
+			size:   image.Pt(6, 3),
+			lines:  []string{"012345", "6789ab", "c     "},
+			cursor: ansi.Pt(6, 2),
+		},
+
+		{
+			name:       "reflow overflow",
+			mode:       ResizeReflow,
+			writes:     []string{"0123456789abc\r\nxy"},
+			size:       image.Pt(4, 3),
+			lines:      []string{"89ab", "c   ", "xy  "},
+			cursor:     ansi.Pt(3, 3),
+			scrollback: []string{"0123\\", "4567\\"},
+		},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			var term Terminal
+			term.Resize(image.Pt(10, 3))
+			term.ResizeMode = tc.mode
+			term.Scrollback = NewScrollback(10)
+			for _, s := range tc.writes {
+				term.WriteString(s)
+			}
+			term.Resize(tc.size)
+			assert.Equal(t, tc.lines, anansitest.GridLines(term.Grid, ' '), "expected grid lines")
+			assert.Equal(t, tc.cursor, term.Point, "expected cursor point")
+			assert.Equal(t, tc.scrollback, scrollbackLines(term.Scrollback), "expected scrollback lines")
+		}))
+	}
+}
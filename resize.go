@@ -0,0 +1,177 @@
+package anansi
+
+import (
+	"errors"
+	"image"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+var errResizeWatcherTerm = errors.New("ResizeWatcher may only be used under a single terminal")
+
+// DefaultResizeCoalesce is the default time that ResizeWatcher waits after a
+// SIGWINCH for any more to arrive, before reading the terminal size.
+const DefaultResizeCoalesce = 20 * time.Millisecond
+
+// Resize is a terminal size change, as delivered by ResizeWatcher.
+type Resize struct {
+	Size   image.Point // in cells
+	Pixels image.Point // in pixels, if reported by the terminal
+}
+
+// ResizeWatcher is a Context that watches for terminal size changes while a
+// terminal is active: bursts of SIGWINCH are coalesced before reading the new
+// size, which may also be polled for terminals behind tools that don't
+// forward SIGWINCH (e.g. some multiplexers or remote sessions). Each change
+// resizes any attached Screen, calls any OnResize callback, and is delivered
+// on the C channel.
+//
+// Changes are handled by a separate goroutine, holding ResizeWatcher's lock
+// while resizing the Screen and calling OnResize; applications should hold it
+// while using the Screen.
+type ResizeWatcher struct {
+	sync.Mutex
+
+	// Screen, if not nil, is resized to the terminal size.
+	Screen *Screen
+
+	// OnResize, if not nil, is called after each size change.
+	OnResize func(Resize)
+
+	// Poll, if non-zero, is an interval at which to also read the terminal
+	// size, in case SIGWINCH isn't delivered.
+	Poll time.Duration
+
+	// Coalesce is how long to wait after a SIGWINCH for any more before
+	// reading the terminal size; defaults to DefaultResizeCoalesce.
+	Coalesce time.Duration
+
+	term *Term
+	last Resize
+	stop chan struct{}
+	done chan struct{}
+
+	cOnce sync.Once
+	c     chan Resize
+}
+
+// C returns a channel that receives the latest terminal size after each
+// change; if a prior change hasn't been received yet, it's replaced.
+func (rw *ResizeWatcher) C() <-chan Resize {
+	rw.cOnce.Do(func() {
+		rw.c = make(chan Resize, 1)
+	})
+	return rw.c
+}
+
+// Size returns the last terminal size read.
+func (rw *ResizeWatcher) Size() Resize {
+	rw.Lock()
+	defer rw.Unlock()
+	return rw.last
+}
+
+// Enter reads the initial terminal size, resizing any Screen, and starts
+// watching for changes.
+func (rw *ResizeWatcher) Enter(term *Term) error {
+	if rw.term != nil {
+		return errResizeWatcherTerm
+	}
+	size, pixels, err := term.WindowSize()
+	if err != nil {
+		return err
+	}
+	rw.C()
+	rw.Lock()
+	rw.last = Resize{size, pixels}
+	if rw.Screen != nil {
+		rw.Screen.Resize(size)
+	}
+	rw.Unlock()
+
+	rw.term = term
+	rw.stop = make(chan struct{})
+	rw.done = make(chan struct{})
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+	go rw.run(term, sigs, rw.stop, rw.done)
+	return nil
+}
+
+// Exit stops watching for changes.
+func (rw *ResizeWatcher) Exit(term *Term) error {
+	if rw.term != term {
+		return nil
+	}
+	close(rw.stop)
+	<-rw.done
+	rw.term, rw.stop, rw.done = nil, nil, nil
+	return nil
+}
+
+func (rw *ResizeWatcher) run(term *Term, sigs chan os.Signal, stop, done chan struct{}) {
+	defer close(done)
+	defer signal.Stop(sigs)
+
+	coalesce := rw.Coalesce
+	if coalesce <= 0 {
+		coalesce = DefaultResizeCoalesce
+	}
+	timer := time.NewTimer(coalesce)
+	timer.Stop()
+	defer timer.Stop()
+
+	var poll <-chan time.Time
+	if rw.Poll > 0 {
+		ticker := time.NewTicker(rw.Poll)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-sigs:
+			timer.Reset(coalesce)
+			continue
+		case <-timer.C:
+		case <-poll:
+		}
+		if err := rw.check(term); err != nil {
+			log.Printf("resize watcher: failed to read terminal size: %v", err)
+		}
+	}
+}
+
+// check reads the terminal size, handling any change.
+func (rw *ResizeWatcher) check(term *Term) error {
+	size, pixels, err := term.WindowSize()
+	if err != nil {
+		return err
+	}
+	rs := Resize{size, pixels}
+
+	rw.Lock()
+	defer rw.Unlock()
+	if rs == rw.last {
+		return nil
+	}
+	rw.last = rs
+	if rw.Screen != nil {
+		rw.Screen.Resize(size)
+	}
+	if rw.OnResize != nil {
+		rw.OnResize(rs)
+	}
+	select {
+	case <-rw.c:
+	default:
+	}
+	rw.c <- rs
+	return nil
+}
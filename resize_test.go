@@ -0,0 +1,77 @@
+package anansi_test
+
+import (
+	"image"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/pty"
+)
+
+func TestResizeWatcher(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		poll   time.Duration
+		notify func() error
+	}{
+		{"poll", 10 * time.Millisecond, func() error { return nil }},
+		{"signal", 0, func() error {
+			for i := 0; i < 3; i++ {
+				if err := syscall.Kill(syscall.Getpid(), syscall.SIGWINCH); err != nil {
+					return err
+				}
+			}
+			return nil
+		}},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			pt, err := pty.Open()
+			if err != nil {
+				t.Skipf("unable to open pty: %v", err)
+			}
+			defer pt.Close()
+			require.NoError(t, pt.SetSize(image.Pt(80, 24), image.ZP))
+
+			var screen Screen
+			var calls int
+			rw := ResizeWatcher{
+				Screen:   &screen,
+				OnResize: func(Resize) { calls++ },
+				Poll:     tc.poll,
+			}
+			term := NewTerm(pt.Slave, &rw)
+			require.NoError(t, term.With(func(term *Term) error {
+				assert.Equal(t, image.Pt(80, 24), rw.Size().Size, "expected initial size")
+				rw.Lock()
+				assert.Equal(t, image.Pt(80, 24), screen.Bounds().Size(), "expected initial screen size")
+				rw.Unlock()
+
+				require.NoError(t, pt.SetSize(image.Pt(100, 30), image.ZP))
+				require.NoError(t, tc.notify())
+				select {
+				case rs := <-rw.C():
+					assert.Equal(t, image.Pt(100, 30), rs.Size, "expected new size")
+				case <-time.After(time.Second):
+					t.Fatal("timed out waiting for resize")
+				}
+
+				select {
+				case rs := <-rw.C():
+					t.Errorf("unexpected extra resize %v", rs)
+				case <-time.After(50 * time.Millisecond):
+				}
+
+				rw.Lock()
+				defer rw.Unlock()
+				assert.Equal(t, image.Pt(100, 30), screen.Bounds().Size(), "expected screen resized")
+				assert.Equal(t, 1, calls, "expected one OnResize call")
+				return nil
+			}))
+		}))
+	}
+}
@@ -11,17 +11,35 @@ import (
 // update the pending ScreenState.
 type Screen struct {
 	ScreenState
-	prior Grid
-	proc  ansi.Buffer
-	out   Cursor
+
+	// NoScroll disables scrolling vertically shifted rows into place (using a
+	// scrolling region), e.g. for terminals that implement it poorly.
+	NoScroll bool
+
+	// OutputProcessing indicates that the terminal post-processes output, so
+	// that LF also implies CR, as reported by Attr.OutputProcessing; cursor
+	// movement then doesn't use LF.
+	OutputProcessing bool
+
+	prior       Grid
+	priorImages []ImagePlacement
+	next        Grid             // grid state built into out, becomes prior once written
+	nextImages  []ImagePlacement // images built into out, become priorImages once written
+	proc        ansi.Buffer
+	out         Cursor
+	partial     bool // out has been partially written
+	shift       shiftFinder
 }
 
 // Reset the internal buffer and restore cursor state to last state affected by
-// WriteTo.
+// WriteTo. However an update that WriteTo has partially written is kept, so
+// that the next WriteTo may complete it (before building another).
 func (sc *Screen) Reset() {
 	sc.ScreenState.Clear()
 	sc.proc.Reset()
-	sc.out.Reset()
+	if !sc.partial {
+		sc.out.Reset()
+	}
 }
 
 // Resize the current screen state, and invalidate to cause a full redraw.
@@ -30,6 +48,7 @@ func (sc *Screen) Resize(size image.Point) bool {
 		sc.Invalidate()
 		sc.proc.Reset()
 		sc.out.Reset()
+		sc.partial = false
 		return true
 	}
 	return false
@@ -38,22 +57,37 @@ func (sc *Screen) Resize(size image.Point) bool {
 // Invalidate forces the next WriteTo() to perform a full redraw.
 func (sc *Screen) Invalidate() {
 	sc.prior.Resize(image.ZP)
+	sc.next.Resize(image.ZP)
 }
 
 // WriteTo builds and writes output based on the current ScreenState, doing a
 // differential update if possible, or a full redraw otherwise. If the internal
 // output buffer isn't empty, then the build step is skipped, and another
-// attempt is made to flush the output buffer.
+// attempt is made to flush the output buffer; the next differential update is
+// then made against the state that was built into it, not any since.
 func (sc *Screen) WriteTo(w io.Writer) (n int64, err error) {
 	if sc.out.buf.Len() == 0 {
-		_, sc.out.CursorState = sc.ScreenState.Update(sc.out.CursorState, &sc.out.buf, sc.prior)
+		sf := &sc.shift
+		if sc.NoScroll {
+			sf = nil
+		}
+		sc.out.CursorState.lfCR = sc.OutputProcessing
+		_, sc.out.CursorState = sc.ScreenState.update(sc.out.CursorState, &sc.out.buf, sc.prior, sc.priorImages, sf)
+		sc.next.Resize(sc.ScreenState.Grid.Bounds().Size())
+		copy(sc.next.Rune, sc.ScreenState.Grid.Rune)
+		copy(sc.next.Attr, sc.ScreenState.Grid.Attr)
+		sc.nextImages = append(sc.nextImages[:0], sc.ScreenState.Images...)
+		sc.ScreenState.Grid.clearDirty()
 	}
 	n, err = sc.out.WriteTo(w)
 	if err == nil {
-		sc.prior.Resize(sc.ScreenState.Grid.Bounds().Size())
-		copy(sc.prior.Rune, sc.ScreenState.Grid.Rune)
-		copy(sc.prior.Attr, sc.ScreenState.Grid.Attr)
-	} else if !isEWouldBlock(err) {
+		sc.partial = false
+		sc.prior, sc.next = sc.next, sc.prior
+		sc.priorImages, sc.nextImages = sc.nextImages, sc.priorImages
+	} else if isEWouldBlock(err) {
+		sc.partial = true
+	} else {
+		sc.partial = false
 		sc.Reset()
 		sc.Invalidate()
 	}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"image"
+	"io"
+	"io/ioutil"
 	"strconv"
 	"testing"
 	"unicode/utf8"
@@ -41,17 +43,17 @@ func TestScreen(t *testing.T) {
 				sc.Clear()
 				i, _ := sc.CellOffset(ansi.Pt(4, 3))
 				sc.Grid.Rune[i], sc.Grid.Attr[i] = '@', ansi.SGRBrightYellow.FG()
-			}, "\x1b[D\x1b[0m \x1b[93m@"},
+			}, "\b\x1b[0m \x1b[93m@"},
 			{func(sc *Screen) {
 				sc.Clear()
 				i, _ := sc.CellOffset(ansi.Pt(4, 4))
 				sc.Grid.Rune[i], sc.Grid.Attr[i] = '@', ansi.SGRGreen.FG()
-			}, "\x1b[D\x1b[0m \x1b[4;4H\x1b[32m@"}, // 5,4
+			}, "\b\x1b[0m \n\b\x1b[32m@"}, // 5,4
 			{func(sc *Screen) {
 				sc.Clear()
 				i, _ := sc.CellOffset(ansi.Pt(3, 4))
 				sc.Grid.Rune[i], sc.Grid.Attr[i] = '@', ansi.SGRYellow.FG()
-			}, "\x1b[2D\x1b[33m@\x1b[0m "},
+			}, "\b\b\x1b[33m@\x1b[0m "},
 		}},
 
 		{"write over", []step{
@@ -59,12 +61,12 @@ func TestScreen(t *testing.T) {
 				sc.Clear()
 				sc.To(ansi.Pt(1, 1))
 				sc.WriteString("hello world!")
-			}, "\x1b[?25l\x1b[2J\x1b[1;1H\x1b[0mhello worl\r\nd!"},
+			}, "\x1b[?25l\x1b[2J\x1b[H\x1b[0mhello worl\r\nd!"},
 			{func(sc *Screen) {
 				sc.Clear()
 				sc.To(ansi.Pt(1, 1))
 				sc.WriteString("\x1b[34mhello world!")
-			}, "\x1b[1;1H\x1b[34mhello worl\r\nd!"},
+			}, "\x1b[H\x1b[34mhello worl\r\nd!"},
 			{func(sc *Screen) {
 				sc.Clear()
 				sc.To(ansi.Pt(1, 1))
@@ -79,19 +81,19 @@ func TestScreen(t *testing.T) {
 				sc.Clear()
 				sc.To(ansi.Pt(1, 1))
 				sc.WriteString("0) --")
-			}, "\x1b[?25l\x1b[2J\x1b[1;1H\x1b[0m0) --"},
+			}, "\x1b[?25l\x1b[2J\x1b[H\x1b[0m0) --"},
 			{func(sc *Screen) {
 				sc.Clear()
 				sc.To(ansi.Pt(1, 1))
 				sc.WriteString("1) ")
 				sc.WriteString("\x1b[31mred")
-			}, "\x1b[5D1\x1b[2C\x1b[31mred"},
+			}, "\r1) \x1b[31mred"},
 			{func(sc *Screen) {
 				sc.Clear()
 				sc.To(ansi.Pt(1, 1))
 				sc.WriteString("2) ")
 				sc.WriteString("\x1b[32mgreen")
-			}, "\x1b[6D\x1b[0m2\x1b[2C\x1b[32mgreen"},
+			}, "\r\x1b[0m2) \x1b[32mgreen"},
 		}},
 
 		{"writing", []step{
@@ -102,7 +104,7 @@ func TestScreen(t *testing.T) {
 				sc.Clear()
 				sc.To(ansi.Pt(1, 1))
 				sc.WriteString("hello world")
-			}, "\x1b[1;1H\x1b[0mhello\x1b[Cworl\r\nd"},
+			}, "\x1b[H\x1b[0mhello worl\r\nd"},
 			{func(sc *Screen) {
 				sc.To(ansi.Pt(1, 1))
 				sc.WriteString("hello ")
@@ -121,17 +123,88 @@ func TestScreen(t *testing.T) {
 				sc.To(ansi.Pt(1, 1))
 				sc.WriteString("hello ")
 				sc.WriteString("\x1b[33mworld")
-			}, "\x1b[2J\x1b[1;1H\x1b[0mhello \x1b[33mworl\r\nd"},
+			}, "\x1b[2J\x1b[H\x1b[0mhello \x1b[33mworl\r\nd"},
 			{func(sc *Screen) {
 				sc.Clear()
 				sc.Resize(image.Pt(20, 10))
 				sc.To(ansi.Pt(1, 1))
 				sc.WriteString("hello ")
 				sc.WriteString("\x1b[34mworld")
-			}, "\x1b[2J\x1b[1;1H\x1b[0mhello \x1b[34mworld"},
+			}, "\x1b[2J\x1b[H\x1b[0mhello \x1b[34mworld"},
 		}},
 
-		// TODO UserCursor
+		{"last column", []step{
+			{func(sc *Screen) {
+				sc.Clear()
+				sc.To(ansi.Pt(9, 9))
+				sc.WriteString("ab")
+			}, "\x1b[?25l\x1b[2J\x1b[9;9H\x1b[0mab"},
+			{func(sc *Screen) {
+				sc.Clear()
+				sc.To(ansi.Pt(9, 9))
+				sc.WriteString("abc")
+			}, "\r\nc"},
+			{func(sc *Screen) {
+				sc.Clear()
+				sc.To(ansi.Pt(9, 10))
+				sc.WriteString("de")
+			}, "\x1b[A\t  \r\n \td\x1b[?7le\x1b[?7h"},
+			{func(sc *Screen) {
+				sc.Clear()
+				sc.To(ansi.Pt(9, 10))
+				sc.WriteString("dz")
+			}, "\x1b[?7lz\x1b[?7h"},
+		}},
+
+		{"images", []step{
+			{func(sc *Screen) {
+				sc.Clear()
+				sc.To(ansi.Pt(1, 2))
+				sc.WriteString("abcd")
+				sc.Images = append(sc.Images, ImagePlacement{
+					Rect:  ansi.Rect(2, 2, 4, 3),
+					Data:  []byte("<img>"),
+					Erase: []byte("<del>"),
+				})
+			}, "\x1b[?25l\x1b[2J\x1b[2;1H\x1b[0mabcd\ra<img>"},
+			{func(sc *Screen) {
+				sc.Clear()
+				sc.To(ansi.Pt(1, 2))
+				sc.WriteString("aBCd")
+				sc.Images = append(sc.Images, ImagePlacement{
+					Rect:  ansi.Rect(2, 2, 4, 3),
+					Data:  []byte("<img>"),
+					Erase: []byte("<del>"),
+				})
+			}, ""},
+			{func(sc *Screen) {
+				sc.Clear()
+				sc.To(ansi.Pt(1, 2))
+				sc.WriteString("aBCd")
+			}, "<del>\x1b[2;2HBC"},
+		}},
+
+		{"user cursor", []step{
+			{func(sc *Screen) {
+				sc.Clear()
+				sc.UserCursor = CursorState{Point: ansi.Pt(2, 2), Visible: true, Shape: ansi.CursorBar}
+			}, "\x1b[?25l\x1b[2J\x1b[2;2H\x1b[0m\x1b[6 q\x1b[?25h"},
+			{func(sc *Screen) {
+				sc.Clear()
+				sc.UserCursor = CursorState{Point: ansi.Pt(2, 2), Visible: true, Shape: ansi.CursorBar}
+			}, "\x1b[?25l\x1b[?25h"},
+			{func(sc *Screen) {
+				sc.Clear()
+				sc.UserCursor = CursorState{Point: ansi.Pt(2, 3), Visible: true, Shape: ansi.CursorBar, Blink: true}
+			}, "\x1b[?25l\x1b[B\x1b[5 q\x1b[?25h"},
+			{func(sc *Screen) {
+				sc.Clear()
+			}, "\x1b[?25l"},
+			{func(sc *Screen) {
+				sc.Clear()
+				sc.UserCursor = CursorState{Point: ansi.Pt(2, 3), Visible: true}
+			}, "\x1b[0 q\x1b[?25h"},
+		}},
 	} {
 		t.Run(tc.name, logBuf.With(func(t *testing.T) {
 			var out bytes.Buffer
@@ -149,6 +222,190 @@ func TestScreen(t *testing.T) {
 	}
 }
 
+func TestScreen_outputProcessing(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		post   bool
+		expect string
+	}{
+		{"raw", false, "\n\nb"},
+		{"post", true, "\x1b[2Bb"},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			var out bytes.Buffer
+			var sc Screen
+			sc.OutputProcessing = tc.post
+			sc.Resize(image.Pt(10, 10))
+			sc.Clear()
+			sc.To(ansi.Pt(2, 2))
+			sc.WriteString("a")
+			_, err := sc.WriteTo(&out)
+			require.NoError(t, err)
+
+			out.Reset()
+			sc.Clear()
+			sc.To(ansi.Pt(2, 2))
+			sc.WriteString("a")
+			sc.To(ansi.Pt(3, 4))
+			sc.WriteString("b")
+			_, err = sc.WriteTo(&out)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, out.String(), "expected output")
+		}))
+	}
+}
+
+func TestScreenState_modes(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		altScreen  bool
+		expandTabs bool
+		writes     []string
+		lines      []string
+		cursor     ansi.Point
+		attr       ansi.SGRAttr
+	}{
+		{
+			name:   "save restore",
+			writes: []string{"\x1b[2;3H\x1b[31m\x1b7\x1b[H\x1b[0mx\x1b8y"},
+			lines:  []string{"x     ", "  \x1b[31my\x1b[0m   ", "      "},
+			cursor: ansi.Pt(4, 2),
+			attr:   ansi.SGRRed.FG(),
+		},
+
+		{
+			name:   "origin mode",
+			writes: []string{"\x1b[2;3r\x1b[?6h\x1b[HX\x1b7\x1b[?6l\x1b[HY\x1b8Z"},
+			lines:  []string{"Y     ", "XZ    ", "      "},
+			cursor: ansi.Pt(3, 2),
+		},
+
+		{
+			name:   "origin mode scroll region",
+			writes: []string{"\x1b[?6h\x1b[2;3rX\x1b[HY"},
+			lines:  []string{"      ", "Y     ", "      "},
+			cursor: ansi.Pt(2, 2),
+		},
+
+		{
+			name:      "alternate screen",
+			altScreen: true,
+			writes:    []string{"main\x1b[?1049h\x1b[Halt"},
+			lines:     []string{"alt   ", "      ", "      "},
+			cursor:    ansi.Pt(4, 1),
+		},
+
+		{
+			name:      "alternate screen exit",
+			altScreen: true,
+			writes:    []string{"main\x1b[?1049h\x1b[Halt\x1b[?1049l"},
+			lines:     []string{"main  ", "      ", "      "},
+			cursor:    ansi.Pt(5, 1),
+		},
+
+		{
+			name:      "alternate buffer kept",
+			altScreen: true,
+			writes:    []string{"main\x1b[?47h\x1b[Halt\x1b[?47l\x1b[2Hx\x1b[?47h"},
+			lines:     []string{"alt   ", "      ", "      "},
+			cursor:    ansi.Pt(2, 2),
+		},
+
+		{
+			name:   "pending wrap",
+			writes: []string{"abcdef"},
+			lines:  []string{"abcdef", "      ", "      "},
+			cursor: ansi.Pt(6, 1),
+		},
+
+		{
+			name:   "pending wrap resolved",
+			writes: []string{"abcdefg"},
+			lines:  []string{"abcdef", "g     ", "      "},
+			cursor: ansi.Pt(2, 2),
+		},
+
+		{
+			name:   "pending wrap cancelled",
+			writes: []string{"abcdef\x1b[2Dg"},
+			lines:  []string{"abcgef", "      ", "      "},
+			cursor: ansi.Pt(5, 1),
+		},
+
+		{
+			name:   "autowrap off",
+			writes: []string{"\x1b[?7labcdefgh"},
+			lines:  []string{"abcdeh", "      ", "      "},
+			cursor: ansi.Pt(6, 1),
+		},
+
+		{
+			name:   "dec special graphics",
+			writes: []string{"\x1b(0lqk\x1b(Bx\r\n", "\x1b)0a\x0eq\x0fq\x1b*A\x1bN#"},
+			lines:  []string{"┌─┐x  ", "a─q£  ", "      "},
+			cursor: ansi.Pt(5, 2),
+		},
+
+		{
+			name:   "saved charset",
+			writes: []string{"\x1b(0\x1b7\x1b(Bq\x1b8q"},
+			lines:  []string{"─     ", "      ", "      "},
+			cursor: ansi.Pt(2, 1),
+		},
+
+		{
+			name:   "tabs",
+			writes: []string{"a\t\x1b[Db\r\n\x1b[3g\x1b[3G\x1bH\r\tc"},
+			lines:  []string{"a   b ", "  c   ", "      "},
+			cursor: ansi.Pt(4, 2),
+		},
+
+		{
+			name:   "tab stops not saved",
+			writes: []string{"\x1b7\x1b[3G\x1bH\x1b8\tx"},
+			lines:  []string{"  x   ", "      ", "      "},
+			cursor: ansi.Pt(4, 1),
+		},
+
+		{
+			name:       "expand tabs",
+			expandTabs: true,
+			writes:     []string{"\x1b[41ma\tb"},
+			lines:      []string{"\x1b[41ma    b", "\x1b[0m      ", "      "},
+			cursor:     ansi.Pt(6, 1),
+			attr:       ansi.SGRRed.BG(),
+		},
+
+		{
+			name:   "alternate screen disabled",
+			writes: []string{"main\x1b[?1049h\x1b[2Halt\x1b[?1049l!"},
+			lines:  []string{"main! ", "alt   ", "      "},
+			cursor: ansi.Pt(6, 1),
+		},
+		{
+			name:   "malformed string",
+			writes: []string{"\x1b]0;caf\xe9\x07ab", "\r\nworld"},
+			lines:  []string{"ab    ", "world ", "      "},
+			cursor: ansi.Pt(6, 2),
+		},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			var sc Screen
+			sc.AltScreen = tc.altScreen
+			sc.ExpandTabs = tc.expandTabs
+			sc.Resize(image.Pt(6, 3))
+			sc.Clear()
+			sc.To(ansi.Pt(1, 1))
+			for _, s := range tc.writes {
+				sc.WriteString(s)
+			}
+			assert.Equal(t, tc.lines, anansitest.GridLines(sc.Grid, ' '), "expected grid lines")
+			assert.Equal(t, tc.cursor, sc.Point, "expected cursor point")
+			assert.Equal(t, tc.attr, sc.CursorState.Attr, "expected cursor attr")
+		}))
+	}
+}
+
 func Test_gridLines(t *testing.T) {
 	for _, tc := range []struct {
 		name  string
@@ -287,13 +544,40 @@ func TestScreen_equiv(t *testing.T) {
 					"\x1b[5;5H\x1b[31m@",
 			},
 		},
+
+		{
+			name:  "log scroll",
+			sz:    image.Pt(20, 8),
+			steps: logViewSteps(image.Pt(20, 8), 0, 12, 1),
+		},
+
+		{
+			name:  "log scroll by 3",
+			sz:    image.Pt(20, 8),
+			steps: logViewSteps(image.Pt(20, 8), 0, 12, 3),
+		},
+
+		{
+			name:  "log scroll under header",
+			sz:    image.Pt(20, 8),
+			steps: logViewSteps(image.Pt(20, 8), 2, 12, 1),
+		},
+
+		{
+			name:  "log scroll back",
+			sz:    image.Pt(20, 8),
+			steps: logViewSteps(image.Pt(20, 8), 1, 12, -2),
+		},
 	} {
 		t.Run(tc.name, logBuf.With(func(t *testing.T) {
-			var a, b, aout, bout Screen
+			var a, b, c, aout, bout, cout Screen
 			a.Resize(tc.sz)
 			b.Resize(tc.sz)
+			c.Resize(tc.sz)
+			c.TrackDirty(true)
 			aout.Resize(tc.sz)
 			bout.Resize(tc.sz)
+			cout.Resize(tc.sz)
 
 			for i, s := range tc.steps {
 				t.Run(fmt.Sprintf("step_%d", i), logBuf.With(func(t *testing.T) {
@@ -309,6 +593,12 @@ func TestScreen_equiv(t *testing.T) {
 					require.NoError(t, err, "unexpected write error")
 					bLines := anansitest.GridLines(bout.Grid, ' ')
 
+					c.Clear()
+					c.WriteString(s)
+					_, err = c.WriteTo(&cout)
+					require.NoError(t, err, "unexpected write error")
+					cLines := anansitest.GridLines(cout.Grid, ' ')
+
 					var aw, bw int
 					for i := range aLines {
 						aLines[i] = strconv.Quote(aLines[i])
@@ -325,8 +615,161 @@ func TestScreen_equiv(t *testing.T) {
 					}
 
 					assert.Equal(t, aLines, bLines, "[%v] expected equivalent output", i)
+					for i := range cLines {
+						cLines[i] = strconv.Quote(cLines[i])
+					}
+					assert.Equal(t, aLines, cLines, "[%v] expected equivalent dirty tracked output", i)
 				}))
 			}
 		}))
 	}
 }
+
+func TestScreen_scrollGain(t *testing.T) {
+	sz := image.Pt(4, 6)
+	rows := func(ss ...string) (s string) {
+		for y, line := range ss {
+			s += fmt.Sprintf("\x1b[%d;1H%s", y+1, line)
+		}
+		return s
+	}
+	for _, tc := range []struct {
+		name   string
+		before string
+		after  string
+		scroll bool
+	}{
+		// rows c-f would be scrolled out of place to move d and e into place
+		{"exposes more than it saves", rows("a", "b", "c", "d", "e", "f"), rows("d", "e", "c", "d", "e", "f"), false},
+		{"log scroll", rows("a", "b", "c", "d", "e", "f"), rows("b", "c", "d", "e", "f", "g"), true},
+		{"mostly blank", rows("", "", "", "", "", "a"), rows("", "", "", "", "a", "b"), false},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			var sc, term Screen
+			sc.Resize(sz)
+			term.Resize(sz)
+			sc.WriteString(tc.before)
+			_, err := sc.WriteTo(&term)
+			require.NoError(t, err, "unexpected write error")
+
+			sc.Clear()
+			sc.WriteString(tc.after)
+			var out bytes.Buffer
+			_, err = sc.WriteTo(io.MultiWriter(&term, &out))
+			require.NoError(t, err, "unexpected write error")
+
+			scrolled := false
+			for b := out.Bytes(); len(b) > 0; {
+				e, _, n := ansi.DecodeEscape(b)
+				if n == 0 {
+					_, n = utf8.DecodeRune(b)
+				}
+				b = b[n:]
+				scrolled = scrolled || e == ansi.SU || e == ansi.SD
+			}
+			assert.Equal(t, tc.scroll, scrolled, "expected scroll usage in %q", out.Bytes())
+			assert.Equal(t, anansitest.GridLines(parseGrid(tc.after, sz), ' '), anansitest.GridLines(term.Grid, ' '), "expected output")
+		}))
+	}
+}
+
+// logViewFrame returns a string that draws a log view into a screen of the
+// given size: the first header rows are static, and the rest show log lines
+// ending with the given line number.
+func logViewFrame(sz image.Point, header, end int) string {
+	var buf bytes.Buffer
+	for y := 1; y <= header; y++ {
+		fmt.Fprintf(&buf, "\x1b[%d;1H\x1b[0;1mheader %d", y, y)
+	}
+	for y := header + 1; y <= sz.Y; y++ {
+		line := end - sz.Y + y
+		if line < 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "\x1b[%d;1H\x1b[0;3%dm%d)\x1b[0m log line", y, line%8, line)
+	}
+	return buf.String()
+}
+
+// logViewSteps returns a series of log view frames, each advanced by delta
+// lines from the last.
+func logViewSteps(sz image.Point, header, n, delta int) (steps []string) {
+	end := sz.Y
+	if delta < 0 {
+		end += -delta * n
+	}
+	for i := 0; i < n; i++ {
+		steps = append(steps, logViewFrame(sz, header, end))
+		end += delta
+	}
+	return steps
+}
+
+func BenchmarkScreen_logView(b *testing.B) {
+	sz := image.Pt(80, 24)
+	frames := logViewSteps(sz, 1, 64, 1)
+	for _, bc := range []struct {
+		name     string
+		redraw   bool
+		noScroll bool
+	}{
+		{"redraw", true, false},
+		{"diff", false, false},
+		{"diff without scroll", false, true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var sc Screen
+			var out bytes.Buffer
+			sc.NoScroll = bc.noScroll
+			sc.Resize(sz)
+			total := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				sc.Clear()
+				sc.WriteString(frames[i%len(frames)])
+				if bc.redraw {
+					sc.Invalidate()
+				}
+				out.Reset()
+				n, err := sc.WriteTo(&out)
+				if err != nil {
+					b.Fatal(err)
+				}
+				total += int(n)
+			}
+			b.ReportMetric(float64(total)/float64(b.N), "bytes/frame")
+		})
+	}
+}
+
+func BenchmarkScreen_dirty(b *testing.B) {
+	sz := image.Pt(300, 100)
+	for _, bc := range []struct {
+		name  string
+		track bool
+	}{
+		{"full scan", false},
+		{"dirty tracked", true},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			var sc Screen
+			sc.Resize(sz)
+			sc.TrackDirty(bc.track)
+			for i := range sc.Grid.Rune {
+				sc.Grid.Rune[i] = rune('a' + i%26)
+			}
+			if _, err := sc.WriteTo(ioutil.Discard); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				// change a single cell each frame
+				pt := ansi.Pt(1+i%sz.X, 1+i%sz.Y)
+				sc.Grid.Set(pt, rune('A'+i%26), ansi.SGRAttrBold)
+				if _, err := sc.WriteTo(ioutil.Discard); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
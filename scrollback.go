@@ -0,0 +1,159 @@
+package anansi
+
+import "github.com/jcorbin/anansi/ansi"
+
+// Scrollback is a bounded buffer of lines scrolled off the top of a
+// ScreenState; see ScreenState.Scrollback. Once its limit is reached, the
+// oldest lines are dropped (and their memory reused) as new ones are pushed.
+type Scrollback struct {
+	limit int
+	lines []scrollbackLine // ring buffer, oldest line at start
+	start int
+}
+
+type scrollbackLine struct {
+	rune    []rune
+	attr    []ansi.SGRAttr
+	wrapped bool
+}
+
+// NewScrollback creates a scrollback buffer that holds at most limit lines.
+func NewScrollback(limit int) *Scrollback {
+	if limit < 1 {
+		limit = 1
+	}
+	return &Scrollback{limit: limit}
+}
+
+// Limit returns the maximum number of lines held.
+func (sb *Scrollback) Limit() int { return sb.limit }
+
+// Len returns the number of lines held.
+func (sb *Scrollback) Len() int { return len(sb.lines) }
+
+// Line returns the runes and attributes of the i-th line, counting from 0 for
+// the oldest line, and whether it was soft-wrapped onto the next line. The
+// returned slices must not be modified, and are only valid until the next
+// Push or Rewrap. Trailing blank cells are trimmed from non-wrapped lines.
+func (sb *Scrollback) Line(i int) (runes []rune, attrs []ansi.SGRAttr, wrapped bool) {
+	if i < 0 || i >= len(sb.lines) {
+		return nil, nil, false
+	}
+	line := &sb.lines[(sb.start+i)%len(sb.lines)]
+	return line.rune, line.attr, line.wrapped
+}
+
+// Push copies a line into the scrollback, dropping the oldest line if the
+// limit has been reached.
+func (sb *Scrollback) Push(runes []rune, attrs []ansi.SGRAttr, wrapped bool) {
+	var line *scrollbackLine
+	if len(sb.lines) < sb.limit {
+		sb.lines = append(sb.lines, scrollbackLine{})
+		line = &sb.lines[len(sb.lines)-1]
+	} else {
+		line = &sb.lines[sb.start]
+		sb.start = (sb.start + 1) % len(sb.lines)
+	}
+	n := len(runes)
+	if !wrapped {
+		for n > 0 && runes[n-1] == 0 && (n > len(attrs) || attrs[n-1] == 0) {
+			n--
+		}
+	}
+	line.rune = append(line.rune[:0], runes[:n]...)
+	line.attr = line.attr[:0]
+	if n <= len(attrs) {
+		line.attr = append(line.attr, attrs[:n]...)
+	} else {
+		line.attr = append(line.attr, attrs...)
+		for len(line.attr) < n {
+			line.attr = append(line.attr, 0)
+		}
+	}
+	line.wrapped = wrapped
+}
+
+// Clear discards all lines.
+func (sb *Scrollback) Clear() {
+	sb.lines = sb.lines[:0]
+	sb.start = 0
+}
+
+// Rewrap re-flows all lines to a new width: runs of soft-wrapped lines are
+// joined back into logical lines, and then split every width cells. Only the
+// newest lines are kept if the result exceeds the limit.
+func (sb *Scrollback) Rewrap(width int) {
+	if width < 1 || len(sb.lines) == 0 {
+		return
+	}
+
+	var out []scrollbackLine
+	var runes []rune
+	var attrs []ansi.SGRAttr
+	for i := 0; i < len(sb.lines); i++ {
+		r, a, wrapped := sb.Line(i)
+		runes, attrs = append(runes, r...), append(attrs, a...)
+		if wrapped && i < len(sb.lines)-1 {
+			continue
+		}
+		for j := 0; j == 0 || j < len(runes); j += width {
+			k := j + width
+			if k > len(runes) {
+				k = len(runes)
+			}
+			out = append(out, scrollbackLine{
+				rune:    append([]rune(nil), runes[j:k]...),
+				attr:    append([]ansi.SGRAttr(nil), attrs[j:k]...),
+				wrapped: k < len(runes) || wrapped,
+			})
+		}
+		runes, attrs = runes[:0], attrs[:0]
+	}
+
+	if len(out) > sb.limit {
+		out = out[len(out)-sb.limit:]
+	}
+	sb.lines, sb.start = out, 0
+}
+
+// RenderView renders a view of the screen grid scrolled back by offset lines
+// into dst, resizing it to match the screen: its top rows are filled with the
+// newest offset lines of scrollback, and the rest from the top of screen.
+// The offset is clamped to the number of lines held, and then returned.
+func (sb *Scrollback) RenderView(dst *Grid, screen Grid, offset int) int {
+	if offset > len(sb.lines) {
+		offset = len(sb.lines)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	dst.Resize(screen.Size)
+	w := screen.Size.X
+	for y := 0; y < screen.Size.Y; y++ {
+		rowRunes := dst.Rune[y*w : y*w+w]
+		rowAttrs := dst.Attr[y*w : y*w+w]
+		if src := y - offset; src >= 0 {
+			copy(rowRunes, screen.Rune[src*w:src*w+w])
+			copy(rowAttrs, screen.Attr[src*w:src*w+w])
+			dst.setWrapped(y, screen.Wrapped(src+1))
+			continue
+		}
+		r, a, wrapped := sb.Line(len(sb.lines) - offset + y)
+		n := copy(rowRunes, r)
+		copy(rowAttrs, a)
+		for i := n; i < w; i++ {
+			rowRunes[i], rowAttrs[i] = 0, 0
+		}
+		dst.setWrapped(y, wrapped)
+	}
+	dst.markDirty(0, screen.Size.Y)
+	return offset
+}
+
+// pushRows pushes the given 0-indexed [top, bottom) rows of a grid.
+func (sb *Scrollback) pushRows(g Grid, top, bottom int) {
+	w := g.Size.X
+	for y := top; y < bottom; y++ {
+		sb.Push(g.Rune[y*w:y*w+w], g.Attr[y*w:y*w+w], g.Wrapped(y+1))
+	}
+}
@@ -0,0 +1,149 @@
+package anansi_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/ansi"
+	anansitest "github.com/jcorbin/anansi/test"
+)
+
+func scrollbackLines(sb *Scrollback) (lines []string) {
+	for i := 0; i < sb.Len(); i++ {
+		r, _, wrapped := sb.Line(i)
+		s := string(r)
+		if wrapped {
+			s += "\\"
+		}
+		lines = append(lines, s)
+	}
+	return lines
+}
+
+func TestScrollback(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		limit  int
+		writes []string
+		resize image.Point
+		lines  []string
+		check  func(t *testing.T, term *Terminal)
+	}{
+		{
+			name:   "capture",
+			limit:  10,
+			writes: []string{"1\r\n2\r\n3\r\n4\r\n5"},
+			lines:  []string{"1", "2"},
+		},
+
+		{
+			name:   "limit",
+			limit:  2,
+			writes: []string{"1\r\n2\r\n3\r\n4\r\n5\r\n6"},
+			lines:  []string{"2", "3"},
+		},
+
+		{
+			name:   "attributes",
+			limit:  10,
+			writes: []string{"\x1b[31mred\x1b[0m\r\n\r\n\r\n"},
+			lines:  []string{"red"},
+			check: func(t *testing.T, term *Terminal) {
+				_, attrs, _ := term.Scrollback.Line(0)
+				assert.Equal(t, []ansi.SGRAttr{ansi.SGRRed.FG(), ansi.SGRRed.FG(), ansi.SGRRed.FG()}, attrs)
+			},
+		},
+
+		{
+			name:   "wrapped",
+			limit:  10,
+			writes: []string{"0123456789abc\r\n\r\n\r\n"},
+			lines:  []string{"0123456789\\", "abc"},
+		},
+
+		{
+			name:   "rewrap narrower",
+			limit:  10,
+			writes: []string{"0123456789abc\r\nx\r\n\r\n\r\n"},
+			resize: image.Pt(5, 3),
+			lines:  []string{"01234\\", "56789\\", "abc", "x"},
+		},
+
+		{
+			name:   "rewrap wider",
+			limit:  10,
+			writes: []string{"0123456789abc\r\nx\r\n\r\n\r\n"},
+			resize: image.Pt(20, 3),
+			lines:  []string{"0123456789abc", "x"},
+		},
+
+		{
+			name:   "rewrap limit",
+			limit:  3,
+			writes: []string{"0123456789abc\r\nx\r\n\r\n\r\n"},
+			resize: image.Pt(5, 3),
+			lines:  []string{"56789\\", "abc", "x"},
+		},
+
+		{
+			name:   "scroll region",
+			limit:  10,
+			writes: []string{"1\r\n2\r\n3\x1b[2;3r\r\n4\r\n5\x1b[r\x1b[3H\r\n6"},
+			lines:  []string{"1"},
+		},
+
+		{
+			name:   "alternate screen",
+			limit:  10,
+			writes: []string{"1\r\n2\r\n3\r\n4", "\x1b[?1049h1\r\n2\r\n3\r\n4\x1b[?1049l"},
+			lines:  []string{"1"},
+		},
+
+		{
+			name:   "erase scrollback",
+			limit:  10,
+			writes: []string{"1\r\n2\r\n3\r\n4\x1b[3J"},
+		},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			var term Terminal
+			term.Resize(image.Pt(10, 3))
+			term.Scrollback = NewScrollback(tc.limit)
+			for _, s := range tc.writes {
+				term.WriteString(s)
+			}
+			if tc.resize != image.ZP {
+				term.Resize(tc.resize)
+			}
+			assert.Equal(t, tc.lines, scrollbackLines(term.Scrollback), "expected scrollback lines")
+			if tc.check != nil {
+				tc.check(t, &term)
+			}
+		}))
+	}
+}
+
+func TestScrollback_RenderView(t *testing.T) {
+	var term Terminal
+	term.Resize(image.Pt(5, 3))
+	term.Scrollback = NewScrollback(10)
+	term.WriteString("a\r\nb\r\nc\r\nd\r\ne")
+
+	var view Grid
+	for _, tc := range []struct {
+		offset int
+		clamp  int
+		lines  []string
+	}{
+		{0, 0, []string{"c    ", "d    ", "e    "}},
+		{1, 1, []string{"b    ", "c    ", "d    "}},
+		{2, 2, []string{"a    ", "b    ", "c    "}},
+		{5, 2, []string{"a    ", "b    ", "c    "}},
+	} {
+		assert.Equal(t, tc.clamp, term.Scrollback.RenderView(&view, term.Grid, tc.offset), "expected clamped offset")
+		assert.Equal(t, tc.lines, anansitest.GridLines(view, ' '), "expected view lines @%v", tc.offset)
+	}
+}
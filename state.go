@@ -21,10 +21,19 @@ type CursorState struct {
 	ansi.Point
 	Attr    ansi.SGRAttr
 	Visible bool
+	Shape   ansi.CursorShape
+	Blink   bool
 
 	attrKnown bool
 	visKnown  bool
-	// TODO other mode bits? like shape?
+
+	// xterm-like pending wrap: after writing into the last column, X stays in
+	// it, and the next graphic rune first moves to the start of the next row;
+	// any cursor movement cancels it.
+	wrapNext    bool
+	autowrapOff bool // DECAWM reset
+
+	lfCR bool // LF implies CR (output post-processing), so isn't used to move
 }
 
 // ScreenState adds a Grid and UserCusor to CursorState, allowing consumers to
@@ -34,6 +43,50 @@ type ScreenState struct {
 	CursorState
 	UserCursor CursorState
 	Grid
+
+	// Images are inline images placed over the grid, drawn by Update after
+	// it; see ImagePlacement.
+	Images []ImagePlacement
+
+	// Scrollback, if not nil, receives any lines scrolled off the top of the
+	// screen, and is rewrapped when its width changes.
+	Scrollback *Scrollback
+
+	// ResizeMode determines what Resize does with existing content.
+	ResizeMode ResizeMode
+
+	// Tabs are the horizontal tab stops, as set by HTS and cleared by TBC;
+	// they're kept here, rather than in CursorState, since they're screen
+	// state that isn't saved along with the cursor (e.g. by DECSC).
+	Tabs TabStops
+
+	// ExpandTabs causes HT to write spaces into the cells that it moves over,
+	// rather than leaving them unchanged.
+	ExpandTabs bool
+
+	// AltScreen enables separate primary and alternate screen grids, switched
+	// by modes 47, 1047, and 1049; otherwise those modes only save and restore
+	// the cursor, as 1048 does.
+	AltScreen bool
+
+	// scrolling region rows; zero values mean the screen edges
+	scrollTop, scrollBottom int
+
+	origin    bool           // DECOM
+	charsets  charsets       // G0-G3 designations and shifts
+	saved     [2]savedCursor // DECSC state for the primary and alternate screens
+	altActive bool
+	alt       Grid        // the inactive screen grid
+	altSB     *Scrollback // the inactive screen scrollback
+}
+
+// savedCursor is the state saved and restored by DECSC and DECRC.
+type savedCursor struct {
+	ansi.Point
+	attr     ansi.SGRAttr
+	origin   bool
+	wrapNext bool
+	charsets charsets
 }
 
 func (cs CursorState) String() string {
@@ -44,27 +97,68 @@ func (scs ScreenState) String() string {
 	return fmt.Sprintf("%v uc:(%v) gridBounds:%v", scs.CursorState, scs.UserCursor, scs.Grid.Bounds())
 }
 
-// Clear the screen grid, and reset the UserCursor (to invisible nowhere).
+// Clear the screen grid, reset the UserCursor (to invisible nowhere), and
+// remove any Images.
+// When dirty tracking, only rows that had content are marked dirty.
 func (scs *ScreenState) Clear() {
-	for i := range scs.Grid.Rune {
-		scs.Grid.Rune[i] = 0
-		scs.Grid.Attr[i] = 0
+	if scs.Grid.Dirty == nil {
+		scs.clearRegion(0, len(scs.Grid.Rune))
+	} else {
+		for y, i := 0, 0; y < scs.Size.Y; y++ {
+			j := i + scs.Size.X
+			for k := i; k < j; k++ {
+				if scs.Grid.Rune[k] != 0 || scs.Grid.Attr[k] != 0 {
+					scs.clearRegion(k, j)
+					break
+				}
+			}
+			i = j
+		}
 	}
 	scs.Point.Point = image.ZP
 	scs.CursorState.Attr = 0
 	scs.UserCursor = CursorState{}
+	scs.Images = scs.Images[:0]
+	scs.scrollTop, scs.scrollBottom = 0, 0
+	scs.charsets = charsets{}
 }
 
-// Resize the underlying Grid, and zero the cursor position if out of bounds.
+// Resize the underlying Grid, preserving its content as specified by
+// ResizeMode. Under the default ResizeReslice mode, the cursor position is
+// zeroed if out of bounds; otherwise it follows the content.
 // Returns true only if the resize was a change, false if it was a no-op.
 func (scs *ScreenState) Resize(size image.Point) bool {
-	if scs.Grid.Resize(size) {
+	if size == scs.Size {
+		return false
+	}
+	switch scs.ResizeMode {
+	case ResizeCrop, ResizeReflow:
+		scs.Point = scs.Grid.resizeContent(size, scs.ResizeMode == ResizeReflow, scs.Point, scs.Scrollback)
+	default:
+		width := scs.Size.X
+		scs.Grid.Resize(size)
+		if scs.Scrollback != nil && size.X != width {
+			scs.Scrollback.Rewrap(size.X)
+		}
 		if !scs.Point.In(scs.Bounds()) {
 			scs.Point.Point = image.ZP
 		}
-		return true
 	}
-	return false
+	if scs.AltScreen {
+		switch {
+		case scs.ResizeMode == ResizeReslice:
+			scs.alt.Resize(size)
+		case scs.altActive:
+			// the inactive primary screen, whose cursor was saved upon switching
+			sc := &scs.saved[0]
+			sc.Point = scs.alt.resizeContent(size, scs.ResizeMode == ResizeReflow, sc.Point, scs.altSB)
+		default:
+			scs.alt.resizeContent(size, false, ansi.Point{}, nil)
+		}
+	}
+	scs.scrollTop, scs.scrollBottom = 0, 0
+	scs.wrapNext = false
+	return true
 }
 
 // Show returns the control sequence necessary to show the cursor if it is not
@@ -91,6 +185,21 @@ func (cs *CursorState) Hide() ansi.Seq {
 	return ansi.Seq{}
 }
 
+// Style returns the control sequence necessary to change the cursor shape and
+// blink state, the zero sequence if they're unchanged. The zero value is taken
+// to be the terminal's default style, so nothing is emitted until a shape has
+// been set.
+func (cs *CursorState) Style(shape ansi.CursorShape, blink bool) ansi.Seq {
+	if shape == ansi.CursorDefault {
+		blink = false
+	}
+	if cs.Shape != shape || cs.Blink != blink {
+		cs.Shape, cs.Blink = shape, blink
+		return shape.Style(blink)
+	}
+	return ansi.Seq{}
+}
+
 // MergeSGR merges the given SGR attribute into Attr, returning the difference.
 func (cs *CursorState) MergeSGR(attr ansi.SGRAttr) ansi.SGRAttr {
 	if !cs.attrKnown {
@@ -104,53 +213,15 @@ func (cs *CursorState) MergeSGR(attr ansi.SGRAttr) ansi.SGRAttr {
 }
 
 // To constructs an ansi control sequence that will move the cursor to the
-// given screen point using absolute (ansi.CUP) or relative
-// (ansi.{CUU,CUD,CUF,CUD}) if possible. Returns a zero sequence if the cursor
-// is already at the given point.
+// given screen point, choosing the shortest of absolute (ansi.{CUP,CHA,VPA}),
+// relative (ansi.{CUU,CUD,CUF,CUB}), or C0 control (CR, CR LF) movements.
+// Returns a zero sequence if the cursor is already at the given point. See
+// MoveTo for a planner that may combine several movements.
 func (cs *CursorState) To(pt ansi.Point) ansi.Seq {
-	if !cs.Point.Valid() {
-		// TODO more nuanced: if X in unknown / Y is unknown?
-		cs.X, cs.Y = pt.X, pt.Y
-		return ansi.CUP.WithPoint(pt)
-	}
-	if pt.Y == cs.Y+1 && pt.X == 1 {
-		cs.X, cs.Y = pt.X, pt.Y
-		return ansi.Escape('\r').With('\n')
-	}
-	if pt.X != cs.X && pt.Y != cs.Y {
-		cs.X, cs.Y = pt.X, pt.Y
-		return ansi.CUP.WithPoint(pt)
-	}
-
-	dx := pt.X - cs.X
-	cs.X = pt.X
-	switch {
-	case dx == 0:
-	case dx == 1:
-		return ansi.CUF.With()
-	case dx == -1:
-		return ansi.CUB.With()
-	case dx > 0:
-		return ansi.CUF.WithInts(dx)
-	case dx < 0:
-		return ansi.CUB.WithInts(-dx)
-	}
-
-	dy := pt.X - cs.X
-	cs.Y = pt.Y
-	switch {
-	case dy == 0:
-	case dy == 1:
-		return ansi.CUD.With()
-	case dy == -1:
-		return ansi.CUU.With()
-	case dy > 0:
-		return ansi.CUD.WithInts(dy)
-	case dy < 0:
-		return ansi.CUU.WithInts(-dy)
-	}
-
-	return ansi.Seq{}
+	seq := cs.moveSeq(pt)
+	cs.X, cs.Y = pt.X, pt.Y
+	cs.wrapNext = false
+	return seq
 }
 
 func (scs *ScreenState) clamp(pt ansi.Point) ansi.Point {
@@ -171,6 +242,7 @@ func (scs *ScreenState) clamp(pt ansi.Point) ansi.Point {
 // To sets the virtual cursor point to the supplied one.
 func (scs *ScreenState) To(pt ansi.Point) {
 	scs.Point = scs.clamp(pt)
+	scs.wrapNext = false
 }
 
 // ApplyTo applies the receiver cursor state into the passed state value,
@@ -180,6 +252,7 @@ func (cs *CursorState) ApplyTo(cur CursorState, buf *ansi.Buffer) (n int, _ Curs
 	if cs.Visible && cs.Point.Valid() {
 		n += buf.WriteSeq(cur.To(cs.Point))
 		n += buf.WriteSGR(cur.MergeSGR(cs.Attr))
+		n += buf.WriteSeq(cur.Style(cs.Shape, cs.Blink))
 		n += buf.WriteSeq(cur.Show())
 	} else {
 		n += buf.WriteSeq(cur.Hide())
@@ -189,26 +262,92 @@ func (cs *CursorState) ApplyTo(cur CursorState, buf *ansi.Buffer) (n int, _ Curs
 
 // Update performs a Grid differential update with the cursor hidden, and then
 // applies any non-zero UserCursor, returning the number of bytes written into
-// the given buffer, and the final cursor state.
+// the given buffer, and the final cursor state. Any Images are drawn as if
+// none had been drawn before; Screen tracks which images are already drawn.
 func (scs *ScreenState) Update(cur CursorState, buf *ansi.Buffer, prior Grid) (n int, _ CursorState) {
+	return scs.update(cur, buf, prior, nil, &shiftFinder{})
+}
+
+// update implements Update, taking the image placements drawn by the prior
+// update: unchanged ones are left in place, masking the grid cells under them
+// from the diff; any others are erased, and their cells repainted. Shifted
+// rows are scrolled into place only if given a shiftFinder.
+func (scs *ScreenState) update(cur CursorState, buf *ansi.Buffer, prior Grid, priorImages []ImagePlacement, sf *shiftFinder) (n int, _ CursorState) {
 	n += buf.WriteSeq(cur.Hide())
-	m, cur := scs.Grid.Update(cur, buf, prior)
-	n += m
+	var m int
+	if len(scs.Images) == 0 && len(priorImages) == 0 {
+		m, cur = scs.Grid.update(cur, buf, prior, scs.Grid, sf)
+		n += m
+	} else {
+		full := len(prior.Rune) == 0 || prior.Size != scs.Size
+		var kept []bool
+		if !full {
+			prior = prior.copy()
+			kept = make([]bool, len(scs.Images))
+		}
+		for _, pi := range priorImages {
+			if i := pi.indexIn(scs.Images); i >= 0 && !full {
+				kept[i] = true
+				continue
+			}
+			m, _ = buf.Write(pi.Erase)
+			n += m
+			if !full {
+				pi.maskCells(scs.Grid, prior, false)
+			}
+		}
+		known := scs.Grid.copy()
+		for i, im := range scs.Images {
+			if kept != nil && kept[i] {
+				im.maskCells(scs.Grid, prior, true)
+			}
+			im.hideCells(known)
+		}
+		// scrolling rows would move images on the terminal
+		m, cur = scs.Grid.update(cur, buf, prior, known, nil)
+		n += m
+		for i, im := range scs.Images {
+			if kept == nil || !kept[i] {
+				n += cur.MoveTo(im.Rect.Min, buf, known, TabStops{})
+				m, _ = buf.Write(im.Data)
+				n += m
+				cur.Point = ansi.ZP // depends on the terminal and protocol
+			}
+		}
+	}
 	m, cur = scs.UserCursor.ApplyTo(cur, buf)
 	n += m
 	return n, cur
 }
 
 // ProcessRune updates the cursor position by the graphic width of the rune.
+// Since CursorState doesn't know the screen width, it only resolves a pending
+// wrap, never entering one; nor does it track tab stops, so HT moves to the
+// next default one (see Cursor and ScreenState, which do).
 func (cs *CursorState) ProcessRune(r rune) {
 	switch {
 	case unicode.IsGraphic(r):
+		if cs.wrapNext {
+			cs.wrapNext = false
+			cs.X = 1
+			cs.Y++
+		}
 		cs.X++ // TODO support double-width runes
 	// TODO anything for other control runes?
+	case r == '\x08': // BS
+		cs.wrapNext = false
+		if cs.X > 1 {
+			cs.X--
+		}
+	case r == '\x09': // HT
+		cs.wrapNext = false
+		cs.X = TabStops{}.Next(cs.X, 0)
 	case r == '\x0A': // LF
+		cs.wrapNext = false
 		cs.Y++
 	case r == '\x0D': // CR
-		cs.X = 0
+		cs.wrapNext = false
+		cs.X = 1
 	}
 }
 
@@ -216,6 +355,9 @@ func (cs *CursorState) ProcessRune(r rune) {
 // Any errors decoding escape arguments are silenced, and the offending escape
 // sequence(s) ignored.
 func (cs *CursorState) ProcessEscape(e ansi.Escape, a []byte) {
+	if !keepsPendingWrap(e) {
+		cs.wrapNext = false
+	}
 	switch e {
 	case ansi.CUU, ansi.CUD, ansi.CUF, ansi.CUB: // relative cursor motion
 		b, _ := e.CSI()
@@ -233,6 +375,36 @@ func (cs *CursorState) ProcessEscape(e ansi.Escape, a []byte) {
 			cs.Point = p
 		}
 
+	case ansi.CHA, ansi.HPA: // absolute column motion
+		if n, ok := decodeCount(a); ok {
+			cs.X = n
+		}
+
+	case ansi.VPA: // absolute row motion
+		if n, ok := decodeCount(a); ok {
+			cs.Y = n
+		}
+
+	case ansi.HPR: // relative column motion
+		if n, ok := decodeCount(a); ok {
+			cs.X += n
+		}
+
+	case ansi.VPR: // relative row motion
+		if n, ok := decodeCount(a); ok {
+			cs.Y += n
+		}
+
+	case ansi.CHT, ansi.CBT: // tab forward / backward
+		if n, ok := decodeCount(a); ok {
+			cs.X = TabStops{}.tab(e == ansi.CHT, cs.X, n, 0)
+		}
+
+	case ansi.DECSCUSR:
+		if shape, blink, _, err := ansi.DecodeCursorStyle(a); err == nil {
+			cs.Shape, cs.Blink = shape, blink
+		}
+
 	case ansi.SGR:
 		if attr, _, err := ansi.DecodeSGR(a); err == nil {
 			cs.Attr = cs.Attr.Merge(attr)
@@ -251,6 +423,8 @@ func (cs *CursorState) ProcessEscape(e ansi.Escape, a []byte) {
 				switch mode {
 				case ansi.ShowCursor: // TODO terminfo
 					cs.Visible = true
+				case ansi.ModeAutoWrap:
+					cs.autowrapOff = false
 				}
 			}
 		}
@@ -267,6 +441,8 @@ func (cs *CursorState) ProcessEscape(e ansi.Escape, a []byte) {
 				switch mode {
 				case ansi.ShowCursor: // TODO terminfo
 					cs.Visible = false
+				case ansi.ModeAutoWrap:
+					cs.autowrapOff = true
 				}
 			}
 		}
@@ -274,20 +450,46 @@ func (cs *CursorState) ProcessEscape(e ansi.Escape, a []byte) {
 	}
 }
 
-// ProcessRune sets the rune into the virtual screen grid.
+// ProcessRune sets the rune into the virtual screen grid, translating it
+// through any designated G0-G3 character set (e.g. DEC Special Graphics).
 func (scs *ScreenState) ProcessRune(r rune) {
 	br := scs.Bounds()
 	switch {
 	case unicode.IsGraphic(r):
-		if i, ok := scs.Grid.CellOffset(scs.Point); ok {
-			scs.Grid.Rune[i], scs.Grid.Attr[i] = r, scs.CursorState.Attr
+		scs.writeRune(scs.charsets.translate(r))
+	case scs.charsets.shift(r):
+	case r == '\x08': // BS
+		scs.wrapNext = false
+		if scs.X > br.Min.X {
+			scs.X--
 		}
-		if scs.X++; scs.X >= br.Max.X {
-			scs.X = br.Min.X
-			scs.linefeed()
+	case r == '\x09': // HT
+		scs.wrapNext = false
+		x := scs.Tabs.Next(scs.X, scs.Size.X)
+		if scs.ExpandTabs {
+			for ; scs.X < x; scs.X++ {
+				scs.Grid.Set(scs.Point, ' ', scs.CursorState.Attr)
+			}
 		}
+		scs.X = x
+	case r == 0x88: // HTS
+		scs.Tabs.Set(scs.X)
 	case r == '\x0A':
+		scs.wrapNext = false
+		scs.linefeed()
+	case r == '\x0D':
+		scs.wrapNext = false
+		scs.X = br.Min.X
+	case r == 0x84: // IND
+		scs.wrapNext = false
 		scs.linefeed()
+	case r == 0x85: // NEL
+		scs.wrapNext = false
+		scs.X = br.Min.X
+		scs.linefeed()
+	case r == 0x8D: // RI
+		scs.wrapNext = false
+		scs.reverseLinefeed()
 	}
 }
 
@@ -296,6 +498,12 @@ func (scs *ScreenState) ProcessRune(r rune) {
 // grid.  Any errors decoding escape arguments are silenced, and the offending
 // escape sequence(s) ignored.
 func (scs *ScreenState) ProcessEscape(e ansi.Escape, a []byte) {
+	if !keepsPendingWrap(e) {
+		scs.wrapNext = false
+	}
+	if scs.charsets.designate(e, a) {
+		return
+	}
 	switch e {
 	case ansi.CUU, ansi.CUD, ansi.CUF, ansi.CUB: // relative cursor motion
 		b, _ := e.CSI()
@@ -307,10 +515,41 @@ func (scs *ScreenState) ProcessEscape(e ansi.Escape, a []byte) {
 		}
 
 	case ansi.CUP: // absolute cursor motion
-		if len(a) == 0 {
-			scs.Point = scs.clamp(ansi.Pt(1, 1))
-		} else if p, _, err := ansi.DecodePoint(a); err == nil {
-			scs.Point = scs.clamp(p)
+		if pt, ok := decodePosition(a); ok {
+			scs.moveTo(pt.X, pt.Y)
+		}
+
+	case ansi.CHA, ansi.HPA: // absolute column motion
+		if n, ok := decodeCount(a); ok {
+			scs.Point = scs.clamp(ansi.Pt(n, scs.Y))
+		}
+
+	case ansi.VPA: // absolute row motion
+		if n, ok := decodeCount(a); ok {
+			scs.moveTo(scs.X, n)
+		}
+
+	case ansi.HPR: // relative column motion
+		if n, ok := decodeCount(a); ok {
+			scs.Point = scs.clamp(ansi.Pt(scs.X+n, scs.Y))
+		}
+
+	case ansi.VPR: // relative row motion
+		if n, ok := decodeCount(a); ok {
+			scs.Point = scs.clamp(ansi.Pt(scs.X, scs.Y+n))
+		}
+
+	case ansi.CHT, ansi.CBT: // tab forward / backward
+		if n, ok := decodeCount(a); ok {
+			scs.X = scs.Tabs.tab(e == ansi.CHT, scs.X, n, scs.Size.X)
+		}
+
+	case ansi.TBC:
+		scs.Tabs.processTBC(scs.X, a)
+
+	case ansi.DECSCUSR:
+		if shape, blink, _, err := ansi.DecodeCursorStyle(a); err == nil {
+			scs.Shape, scs.Blink = shape, blink
 		}
 
 	case ansi.SGR:
@@ -319,16 +558,16 @@ func (scs *ScreenState) ProcessEscape(e ansi.Escape, a []byte) {
 		}
 
 	case ansi.ED:
-		var val byte
+		val := byte('0')
 		if len(a) == 1 {
 			val = a[0]
-		} else {
+		} else if len(a) > 1 {
 			return
 		}
 		switch val {
 		case '0': // Erase from current position to bottom of screen inclusive
 			if i, ok := scs.CellOffset(scs.Point); ok {
-				scs.clearRegion(i+1, len(scs.Rune))
+				scs.clearRegion(i, len(scs.Rune))
 			}
 		case '1': // Erase from top of screen to current position inclusive
 			if i, ok := scs.CellOffset(scs.Point); ok {
@@ -336,13 +575,17 @@ func (scs *ScreenState) ProcessEscape(e ansi.Escape, a []byte) {
 			}
 		case '2': // Erase entire screen (without moving the cursor)
 			scs.clearRegion(0, len(scs.Rune))
+		case '3': // Erase scrollback
+			if scs.Scrollback != nil {
+				scs.Scrollback.Clear()
+			}
 		}
 
 	case ansi.EL:
-		var val byte
+		val := byte('0')
 		if len(a) == 1 {
 			val = a[0]
-		} else {
+		} else if len(a) > 1 {
 			return
 		}
 
@@ -367,40 +610,332 @@ func (scs *ScreenState) ProcessEscape(e ansi.Escape, a []byte) {
 			scs.clearRegion(i, j+1)
 		}
 
-		// case ansi.DECSTBM: TODO
-		// [12;24r Set scrolling region to lines 12 thru 24.  If a linefeed or an
-		//         INDex is received while on line 24, the former line 12 is
-		//         deleted and rows 13-24 move up.  If a RI (reverse Index) is
-		//         received while on line 12, a blank line is inserted there as
-		//         rows 12-13 move down.  All VT100 compatible terminals (except
-		//         GIGI) have this feature.
+	case ansi.DECSTBM:
+		top, bottom := 0, 0
+		if len(a) > 0 && a[0] != ';' {
+			var n int
+			var err error
+			if top, n, err = ansi.DecodeNumber(a); err != nil {
+				return
+			}
+			a = a[n:]
+		}
+		if len(a) > 0 {
+			var err error
+			if bottom, _, err = ansi.DecodeNumber(a); err != nil {
+				return
+			}
+		}
+		// like xterm, default or clamp both ends to the screen, ignoring any
+		// region that isn't at least two rows
+		if top < 1 {
+			top = 1
+		}
+		if bottom < 1 || bottom > scs.Size.Y {
+			bottom = scs.Size.Y
+		}
+		if top >= bottom {
+			return
+		}
+		if top == 1 {
+			top = 0
+		}
+		if bottom == scs.Size.Y {
+			bottom = 0
+		}
+		scs.scrollTop, scs.scrollBottom = top, bottom
+		scs.moveTo(1, 1)
+
+	case ansi.SU, ansi.SD: // scroll up / down
+		n, ok := decodeCount(a)
+		if !ok {
+			return
+		}
+		if e == ansi.SD {
+			n = -n
+		}
+		scs.scrollBy(n)
+
+	case ansi.IL, ansi.DL: // insert / delete lines
+		n, ok := decodeCount(a)
+		if !ok {
+			return
+		}
+		top, bottom := scs.scrollRegion()
+		if scs.Y < top || scs.Y >= bottom {
+			return
+		}
+		if e == ansi.IL {
+			n = -n
+		}
+		scs.Grid.shiftRows(scs.Y-1, bottom-1, n)
+		scs.X = 1
+
+	case ansi.SM, ansi.RM:
+		scs.setModes(e == ansi.SM, a)
+
+	case ansi.DECSC:
+		scs.saveCursor()
+	case ansi.DECRC:
+		scs.restoreCursor()
+
+	case ansi.ESC('n'): // LS2
+		scs.charsets.gl = 2
+	case ansi.ESC('o'): // LS3
+		scs.charsets.gl = 3
 	}
 }
 
 func (scs *ScreenState) clearRegion(i, max int) {
+	if i < max && scs.Size.X > 0 {
+		scs.markDirty(i/scs.Size.X, (max-1)/scs.Size.X+1)
+		// rows whose last cell is erased no longer wrap
+		for y := i / scs.Size.X; y < max/scs.Size.X; y++ {
+			scs.Grid.setWrapped(y, false)
+		}
+	}
 	for ; i < max; i++ {
 		scs.Grid.Rune[i] = 0
 		scs.Grid.Attr[i] = 0
 	}
 }
 
+// scrollRegion returns the 1-indexed [top, bottom) rows of the current
+// scrolling region.
+func (scs *ScreenState) scrollRegion() (top, bottom int) {
+	top, bottom = 1, scs.Size.Y+1
+	if scs.scrollTop > 0 {
+		top = scs.scrollTop
+	}
+	if scs.scrollBottom > 0 && scs.scrollBottom < scs.Size.Y {
+		bottom = scs.scrollBottom + 1
+	}
+	return top, bottom
+}
+
 func (scs *ScreenState) linefeed() {
-	if scs.Y+1 < scs.Bounds().Max.Y {
-		scs.Y++
-	} else {
+	if _, bottom := scs.scrollRegion(); scs.Y+1 == bottom {
 		scs.scrollBy(1)
+	} else if scs.Y+1 < scs.Bounds().Max.Y {
+		scs.Y++
+	}
+}
+
+func (scs *ScreenState) reverseLinefeed() {
+	if top, _ := scs.scrollRegion(); scs.Y == top {
+		scs.scrollBy(-1)
+	} else if scs.Y > 1 {
+		scs.Y--
 	}
 }
 
+// scrollBy scrolls the contents of the scrolling region up by n rows (down if
+// n is negative); rows scrolled off the top of the screen are pushed into any
+// Scrollback.
 func (scs *ScreenState) scrollBy(n int) {
-	i, ok := scs.CellOffset(scs.Point.Add(image.Pt(1, 2)))
-	if !ok {
+	top, bottom := scs.scrollRegion()
+	if n > 0 && top == 1 && scs.Scrollback != nil {
+		k := n
+		if k > bottom-top {
+			k = bottom - top
+		}
+		scs.Scrollback.pushRows(scs.Grid, 0, k)
+	}
+	scs.Grid.shiftRows(top-1, bottom-1, n)
+}
+
+// writeRune writes a graphic rune at the cursor, and advances it; writing into
+// the last column enters a pending wrap, unless autowrap is disabled.
+func (scs *ScreenState) writeRune(r rune) {
+	scs.resolveWrap()
+	scs.Grid.Set(scs.Point, r, scs.CursorState.Attr)
+	if scs.X < scs.Size.X {
+		scs.X++
+	} else if !scs.autowrapOff {
+		scs.wrapNext = true
+	}
+}
+
+// resolveWrap moves the cursor to the start of the next row, marking the
+// current row as soft-wrapped, if a wrap is pending.
+func (scs *ScreenState) resolveWrap() {
+	if scs.wrapNext {
+		scs.wrapNext = false
+		scs.X = 1
+		scs.Grid.setWrapped(scs.Y-1, true)
+		scs.linefeed()
+	}
+}
+
+// keepsPendingWrap returns true if the given escape sequence leaves any
+// pending wrap intact, since it doesn't affect the cursor position.
+func keepsPendingWrap(e ansi.Escape) bool {
+	switch e {
+	case ansi.SGR, ansi.SM, ansi.RM, ansi.DA, ansi.DSR, ansi.DECSC,
+		ansi.Escape(0x9D): // OSC
+		return true
+	}
+	return e.IsCharacterSetControl()
+}
+
+// moveTo moves the cursor to the given point, which is relative to the
+// scrolling region in origin mode; a zero coordinate is treated as 1.
+func (scs *ScreenState) moveTo(x, y int) {
+	if x < 1 {
+		x = 1
+	}
+	if y < 1 {
+		y = 1
+	}
+	if scs.origin {
+		top, bottom := scs.scrollRegion()
+		if y += top - 1; y >= bottom {
+			y = bottom - 1
+		}
+	}
+	scs.Point = scs.clamp(ansi.Pt(x, y))
+}
+
+// setModes processes SM and RM arguments, ignoring any unsupported modes.
+func (scs *ScreenState) setModes(set bool, a []byte) {
+	private := len(a) > 0 && a[0] == '?'
+	n := 0
+	if private {
+		n++
+	}
+	for n < len(a) {
+		mode, m, err := ansi.DecodeMode(private, a[n:])
+		if err != nil || m == 0 {
+			return
+		}
+		n += m
+		scs.setMode(mode, set)
+	}
+}
+
+// setMode sets or resets origin mode, or switches screens and saves or
+// restores the cursor; returns false for any other mode.
+func (scs *ScreenState) setMode(mode ansi.Mode, set bool) bool {
+	switch mode {
+	case ansi.ModeOrigin:
+		scs.origin = set
+		scs.moveTo(1, 1)
+	case ansi.ModeAutoWrap:
+		scs.autowrapOff = !set
+	case ansi.ModeAlternateBuffer:
+		scs.switchScreen(set, false)
+	case ansi.ModeAlternateBufferClear:
+		if !set && scs.altActive {
+			scs.clearRegion(0, len(scs.Grid.Rune))
+		}
+		scs.switchScreen(set, false)
+	case ansi.ModeSaveCursor:
+		if set {
+			scs.saveCursor()
+		} else {
+			scs.restoreCursor()
+		}
+	case ansi.ModeAlternateScreen:
+		if set {
+			scs.saveCursor()
+			scs.switchScreen(true, true)
+		} else {
+			scs.switchScreen(false, false)
+			scs.restoreCursor()
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// switchScreen switches between the primary and alternate screens, optionally
+// clearing the alternate screen when switching to it; it does nothing unless
+// AltScreen is enabled.
+func (scs *ScreenState) switchScreen(alt, clear bool) {
+	if !scs.AltScreen {
 		return
 	}
-	for j := copy(scs.Grid.Rune, scs.Grid.Rune[i:]); j < len(scs.Grid.Rune); j++ {
-		scs.Grid.Rune[j] = 0
+	if alt != scs.altActive {
+		if scs.Grid.Dirty != nil && scs.alt.Dirty == nil {
+			scs.alt.TrackDirty(true)
+		}
+		if scs.alt.Size != scs.Size {
+			scs.alt.Resize(scs.Size)
+			scs.alt.clearRows(0, scs.Size.Y)
+		}
+		scs.Grid, scs.alt = scs.alt, scs.Grid
+		// lines scrolled off the alternate screen aren't kept
+		scs.Scrollback, scs.altSB = scs.altSB, scs.Scrollback
+		scs.altActive = alt
+		scs.markDirty(0, scs.Size.Y)
+	}
+	if alt && clear {
+		scs.clearRegion(0, len(scs.Grid.Rune))
+	}
+}
+
+func (scs *ScreenState) screenIndex() int {
+	if scs.altActive {
+		return 1
+	}
+	return 0
+}
+
+func (scs *ScreenState) saveCursor() {
+	scs.saved[scs.screenIndex()] = savedCursor{
+		Point:    scs.Point,
+		attr:     scs.CursorState.Attr,
+		origin:   scs.origin,
+		wrapNext: scs.wrapNext,
+		charsets: scs.charsets,
+	}
+}
+
+func (scs *ScreenState) restoreCursor() {
+	sc := scs.saved[scs.screenIndex()]
+	if !sc.Valid() {
+		sc.Point = ansi.Pt(1, 1)
+	}
+	scs.Point = scs.clamp(sc.Point)
+	scs.CursorState.Attr = sc.attr
+	scs.origin = sc.origin
+	scs.wrapNext = sc.wrapNext
+	scs.charsets = sc.charsets
+}
+
+// decodePosition decodes optional row and column arguments, as used by CUP;
+// either may be omitted, leaving its coordinate zero.
+func decodePosition(a []byte) (pt ansi.Point, ok bool) {
+	if len(a) > 0 && a[0] != ';' {
+		var n int
+		var err error
+		if pt.Y, n, err = ansi.DecodeNumber(a); err != nil {
+			return pt, false
+		}
+		a = a[n:]
+	}
+	if len(a) > 0 {
+		var err error
+		if pt.X, _, err = ansi.DecodeNumber(a); err != nil {
+			return pt, false
+		}
+	}
+	return pt, true
+}
+
+// decodeCount decodes an optional numeric control sequence argument, as used
+// for counts and positions, which default to 1 (as does an explicit 0).
+func decodeCount(a []byte) (int, bool) {
+	if len(a) == 0 {
+		return 1, true
+	}
+	n, _, err := ansi.DecodeNumber(a)
+	if err != nil {
+		return 0, false
 	}
-	for j := copy(scs.Grid.Attr, scs.Grid.Attr[i:]); j < len(scs.Grid.Attr); j++ {
-		scs.Grid.Attr[j] = 0
+	if n == 0 {
+		n = 1
 	}
+	return n, true
 }
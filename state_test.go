@@ -0,0 +1,69 @@
+package anansi_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/ansi"
+	anansitest "github.com/jcorbin/anansi/test"
+)
+
+func TestScreenState_scrollRegion(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		writes []string
+		lines  []string
+		cursor ansi.Point
+	}{
+		{
+			name:   "region",
+			writes: []string{"a\r\nb\r\nc\x1b[2;3r\x1b[3Hd\ne"},
+			lines:  []string{"a   ", "d   ", " e  ", "    "},
+			cursor: ansi.Pt(3, 3),
+		},
+		{
+			name:   "bottom clamped",
+			writes: []string{"a\r\nb\r\nc\x1b[2;40r\x1b[4Hd\ne"},
+			lines:  []string{"a   ", "c   ", "d   ", " e  "},
+			cursor: ansi.Pt(3, 4),
+		},
+		{
+			name:   "top beyond screen",
+			writes: []string{"a\r\nb\x1b[30r\x1b[4Hc\nd"},
+			lines:  []string{"b   ", "    ", "c   ", " d  "},
+			cursor: ansi.Pt(3, 4),
+		},
+		{
+			name:   "both beyond screen",
+			writes: []string{"a\r\nb\x1b[30;40r\x1b[4Hc\nd"},
+			lines:  []string{"b   ", "    ", "c   ", " d  "},
+			cursor: ansi.Pt(3, 4),
+		},
+		{
+			name:   "top at bottom",
+			writes: []string{"a\r\nb\x1b[4r\x1b[4Hc\nd"},
+			lines:  []string{"b   ", "    ", "c   ", " d  "},
+			cursor: ansi.Pt(3, 4),
+		},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			var sc Screen
+			sc.Resize(image.Pt(4, 4))
+			sc.Clear()
+			sc.To(ansi.Pt(1, 1))
+			var term Terminal
+			term.Resize(image.Pt(4, 4))
+			for _, s := range tc.writes {
+				sc.WriteString(s)
+				term.WriteString(s)
+			}
+			assert.Equal(t, tc.lines, anansitest.GridLines(sc.Grid, ' '), "expected screen lines")
+			assert.Equal(t, tc.cursor, sc.Point, "expected screen cursor")
+			assert.Equal(t, tc.lines, anansitest.GridLines(term.Grid, ' '), "expected terminal lines")
+			assert.Equal(t, tc.cursor, term.Point, "expected terminal cursor")
+		}))
+	}
+}
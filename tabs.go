@@ -0,0 +1,126 @@
+package anansi
+
+// tabWidth is the distance between default horizontal tab stops.
+const tabWidth = 8
+
+// nextTabStop returns the column of the first default tab stop after the
+// given column.
+func nextTabStop(x int) int {
+	return ((x-1)/tabWidth+1)*tabWidth + 1
+}
+
+// TabStops tracks horizontal tab stop columns; the zero value has the default
+// stops every 8 columns. Columns beyond any explicitly set or cleared ones
+// keep their default stops, unless all stops have been cleared.
+type TabStops struct {
+	stops   []bool // explicit stops, indexed by column-1
+	cleared bool   // no default stops past len(stops)
+}
+
+// IsStop returns true if there is a tab stop at the given column.
+func (ts TabStops) IsStop(x int) bool {
+	if x < 1 {
+		return false
+	}
+	if x <= len(ts.stops) {
+		return ts.stops[x-1]
+	}
+	return !ts.cleared && x > 1 && (x-1)%tabWidth == 0
+}
+
+// Set a tab stop at the given column (HTS).
+func (ts *TabStops) Set(x int) {
+	if x >= 1 {
+		ts.extend(x)
+		ts.stops[x-1] = true
+	}
+}
+
+// Clear any tab stop at the given column (TBC 0).
+func (ts *TabStops) Clear(x int) {
+	if x >= 1 {
+		ts.extend(x)
+		ts.stops[x-1] = false
+	}
+}
+
+// ClearAll clears all tab stops (TBC 3).
+func (ts *TabStops) ClearAll() {
+	ts.stops = ts.stops[:0]
+	ts.cleared = true
+}
+
+// Reset restores the default tab stops.
+func (ts *TabStops) Reset() {
+	ts.stops = ts.stops[:0]
+	ts.cleared = false
+}
+
+// Next returns the column of the next tab stop after x. If max is positive,
+// the result is limited to it, which is also returned if there are no further
+// stops; otherwise x is returned if there are no further stops.
+func (ts TabStops) Next(x, max int) int {
+	if x < 1 {
+		x = 1
+	}
+	next := x
+	for c := x + 1; c <= len(ts.stops); c++ {
+		if ts.stops[c-1] {
+			next = c
+			break
+		}
+	}
+	if next == x && !ts.cleared {
+		if x < len(ts.stops) {
+			next = nextTabStop(len(ts.stops))
+		} else {
+			next = nextTabStop(x)
+		}
+	}
+	if max > 0 && (next > max || next == x) {
+		next = max
+	}
+	return next
+}
+
+// Prev returns the column of the previous tab stop before x, or 1 if there
+// are no prior stops.
+func (ts TabStops) Prev(x int) int {
+	for x--; x > 1; x-- {
+		if ts.IsStop(x) {
+			return x
+		}
+	}
+	return 1
+}
+
+// tab moves n tab stops forward or backward from x, limited to max if positive
+// (see Next).
+func (ts TabStops) tab(forward bool, x, n, max int) int {
+	for ; n > 0; n-- {
+		if forward {
+			x = ts.Next(x, max)
+		} else {
+			x = ts.Prev(x)
+		}
+	}
+	return x
+}
+
+// processTBC clears the tab stop at column x, or all tab stops, as directed by
+// TBC arguments.
+func (ts *TabStops) processTBC(x int, a []byte) {
+	switch string(a) {
+	case "", "0":
+		ts.Clear(x)
+	case "3":
+		ts.ClearAll()
+	}
+}
+
+// extend the explicit stops to cover column x.
+func (ts *TabStops) extend(x int) {
+	for len(ts.stops) < x {
+		ts.stops = append(ts.stops, ts.IsStop(len(ts.stops)+1))
+	}
+}
@@ -9,6 +9,7 @@ import (
 func NewTerm(f *os.File, cs ...Context) *Term {
 	term := &Term{File: f}
 	term.ctx = Contexts(&term.Attr, Contexts(cs...))
+	term.crash = findCrash(term.ctx)
 	return term
 }
 
@@ -20,21 +21,34 @@ type Term struct {
 
 	active bool
 	ctx    Context
+	crash  *Crash
 }
 
 // With runs the given function within the terminal's context, activating it if
 // necessary, and deactivating it if activation was necessary. If With Enter()
-// context, then it calls context Exit() even after error or panic.
+// context, then it calls context Exit() even after error or panic. If the
+// context includes a Crash, then any panic is recovered and reported, and a
+// *Panic error returned.
 func (term *Term) With(within func(*Term) error) (err error) {
 	if term.active {
 		return within(term)
 	}
 	defer func() {
+		var pnc *Panic
+		if term.crash != nil {
+			if e := recover(); e != nil {
+				pnc = term.crash.recovered(term, e)
+			}
+		}
 		if cerr := term.ctx.Exit(term); cerr == nil {
 			term.active = false
 		} else if err == nil {
 			err = cerr
 		}
+		if pnc != nil {
+			term.crash.report(term, pnc)
+			err = pnc
+		}
 	}()
 	if err = term.ctx.Enter(term); err == nil {
 		term.active = true
@@ -0,0 +1,379 @@
+package anansi
+
+import (
+	"bytes"
+	"image"
+	"unicode"
+
+	"github.com/jcorbin/anansi/ansi"
+)
+
+// Terminal is a virtual terminal emulator: it processes the output of a
+// terminal program, tracking xterm-compatible terminal state, making it
+// suitable for building multiplexers, recorders, and test harnesses.
+//
+// On top of ScreenState, which tracks tab stops, autowrap (with xterm-like
+// pending wrap), origin mode, saved cursor state (DECSC/DECRC), cursor style
+// (DECSCUSR), G0-G3 character sets, and (as enabled by Terminal) alternate
+// screens, it tracks insert mode, window title, and any other set or reset
+// modes.
+//
+// Terminal must be sized by Resize before use; its ScreenState may then be
+// rendered (e.g. by Update) like any other.
+type Terminal struct {
+	ScreenState
+
+	// Title is the window title, as last set by OSC 0 or 2.
+	Title string
+
+	// Replies, if not nil, receives any replies generated by the terminal in
+	// response to queries like DA or DSR. Sends block, so the channel must be
+	// serviced (or buffered) by the caller.
+	Replies chan<- []byte
+
+	proc ansi.Buffer
+
+	inited   bool
+	modes    map[ansi.Mode]bool
+	lastRune rune
+
+	insert  bool // IRM
+	newline bool // LNM
+}
+
+// Resize the terminal's screens according to ResizeMode; the inactive
+// alternate screen is only ever cropped. Tab stops are kept, and the cursor
+// is clamped to the new size.
+func (term *Terminal) Resize(size image.Point) bool {
+	if !term.inited {
+		term.AltScreen = true
+		term.ScreenState.Resize(size)
+		term.Reset()
+		return true
+	}
+	pt := term.Point
+	if !term.ScreenState.Resize(size) {
+		return false
+	}
+	if !term.Point.Valid() {
+		term.Point = term.clamp(pt)
+	}
+	return true
+}
+
+// Reset the terminal to its initial state (RIS): screens are cleared, and all
+// modes, tab stops, character sets and saved cursors are reset.
+func (term *Terminal) Reset() {
+	if term.altActive {
+		term.switchScreen(false, false)
+	}
+	term.alt.Resize(term.Size)
+	for i := range term.alt.Rune {
+		term.alt.Rune[i] = 0
+		term.alt.Attr[i] = 0
+	}
+	term.Clear()
+	term.CursorState = CursorState{
+		Point:     ansi.Pt(1, 1),
+		Visible:   true,
+		attrKnown: true,
+		visKnown:  true,
+	}
+	term.Tabs.Reset()
+	term.softReset()
+	term.lastRune = 0
+	term.inited = true
+}
+
+// softReset resets modes and state like DECSTR does, leaving screen content
+// and cursor position intact.
+func (term *Terminal) softReset() {
+	term.modes = make(map[ansi.Mode]bool)
+	term.origin = false
+	term.autowrapOff = false
+	term.insert = false
+	term.newline = false
+	term.wrapNext = false
+	term.Visible = true
+	term.CursorState.Attr = 0
+	term.scrollTop, term.scrollBottom = 0, 0
+	term.charsets = charsets{}
+	term.saved = [2]savedCursor{}
+}
+
+// Mode returns true if the given mode is set.
+func (term *Terminal) Mode(mode ansi.Mode) bool {
+	switch mode {
+	case ansi.ModeOrigin:
+		return term.origin
+	case ansi.ModeAutoWrap:
+		return !term.autowrapOff
+	case ansi.ModeInsert:
+		return term.insert
+	case ansi.ModeNewline:
+		return term.newline
+	case ansi.ShowCursor:
+		return term.Visible
+	case ansi.ModeAlternateBuffer, ansi.ModeAlternateBufferClear, ansi.ModeAlternateScreen:
+		return term.altActive
+	}
+	return term.modes[mode]
+}
+
+// Write processes terminal output, updating the terminal state. Any incomplete
+// escape sequence at the end of p is buffered until completed by a later
+// write.
+func (term *Terminal) Write(p []byte) (n int, err error) {
+	n, _ = term.proc.Write(p)
+	term.proc.Process(term)
+	term.proc.Discard()
+	return n, nil
+}
+
+// WriteString processes terminal output, like Write.
+func (term *Terminal) WriteString(s string) (n int, err error) {
+	n, _ = term.proc.WriteString(s)
+	term.proc.Process(term)
+	term.proc.Discard()
+	return n, nil
+}
+
+// ProcessRune writes graphic runes into the active screen, and processes
+// control runes.
+func (term *Terminal) ProcessRune(r rune) {
+	switch {
+	case unicode.IsGraphic(r):
+		term.writeRune(term.charsets.translate(r))
+	case r == '\x08': // BS
+		term.wrapNext = false
+		if term.X > 1 {
+			term.X--
+		}
+	case r == '\x0A', r == '\x0B', r == '\x0C': // LF, VT, FF
+		term.wrapNext = false
+		if term.newline {
+			term.X = 1
+		}
+		term.linefeed()
+	default:
+		term.ScreenState.ProcessRune(r)
+	}
+}
+
+// ProcessEscape processes escape sequences, updating terminal state. Any
+// errors decoding escape arguments are silenced, and the offending escape
+// sequence(s) ignored.
+func (term *Terminal) ProcessEscape(e ansi.Escape, a []byte) {
+	if !keepsPendingWrap(e) {
+		term.wrapNext = false
+	}
+
+	switch e {
+	case ansi.CUP, ansi.HVP:
+		if pt, ok := decodePosition(a); ok {
+			term.moveTo(pt.X, pt.Y)
+		}
+
+	case ansi.VPA:
+		if n, ok := decodeCount(a); ok {
+			term.moveTo(term.X, n)
+		}
+
+	case ansi.CUU, ansi.CUD, ansi.CNL, ansi.CPL:
+		n, ok := decodeCount(a)
+		if !ok {
+			return
+		}
+		if e == ansi.CUU || e == ansi.CPL {
+			n = -n
+		}
+		top, bottom := 1, term.Size.Y+1
+		if t, b := term.scrollRegion(); t <= term.Y && term.Y < b {
+			top, bottom = t, b
+		}
+		y := term.Y + n
+		if y < top {
+			y = top
+		} else if y >= bottom {
+			y = bottom - 1
+		}
+		term.Y = y
+		if e == ansi.CNL || e == ansi.CPL {
+			term.X = 1
+		}
+
+	case ansi.ICH, ansi.DCH, ansi.ECH:
+		n, ok := decodeCount(a)
+		if !ok {
+			return
+		}
+		switch e {
+		case ansi.ICH:
+			term.insertCells(n)
+		case ansi.DCH:
+			term.deleteCells(n)
+		case ansi.ECH:
+			term.eraseCells(n)
+		}
+
+	case ansi.REP:
+		if n, ok := decodeCount(a); ok && term.lastRune != 0 {
+			for ; n > 0; n-- {
+				term.writeRune(term.lastRune)
+			}
+		}
+
+	case ansi.SM, ansi.RM:
+		term.setModes(e == ansi.SM, a)
+
+	case ansi.DA:
+		switch {
+		case len(a) == 0, string(a) == "0":
+			term.reply(ansi.DA.With('?').WithInts(62, 22))
+		case a[0] == '>':
+			term.reply(ansi.DA.With('>').WithInts(1, 10, 0))
+		}
+
+	case ansi.DSR:
+		switch string(a) {
+		case "5": // status
+			term.reply(ansi.DSR.WithInts(0))
+		case "6": // cursor position
+			pt := term.Point
+			if term.origin {
+				top, _ := term.scrollRegion()
+				pt.Y -= top - 1
+			}
+			term.reply(ansi.CPR.WithPoint(pt))
+		}
+
+	case ansi.DECSTR:
+		if string(a) == "!" {
+			term.softReset()
+		}
+
+	case ansi.Escape(0x9D): // OSC
+		if i := bytes.IndexByte(a, ';'); i >= 0 {
+			switch string(a[:i]) {
+			case "0", "2":
+				term.Title = string(a[i+1:])
+			}
+		}
+
+	case ansi.ESC('c'): // RIS
+		term.Reset()
+
+	case ansi.ESC('#'):
+		if string(a) == "8" { // DECALN
+			for i := range term.Grid.Rune {
+				term.Grid.Rune[i], term.Grid.Attr[i] = 'E', 0
+			}
+			term.markDirty(0, term.Size.Y)
+			term.scrollTop, term.scrollBottom = 0, 0
+			term.moveTo(1, 1)
+		}
+
+	default:
+		term.ScreenState.ProcessEscape(e, a)
+	}
+}
+
+// writeRune writes a graphic rune into the active screen like ScreenState
+// does, additionally handling insert mode.
+func (term *Terminal) writeRune(r rune) {
+	term.resolveWrap()
+	if term.insert {
+		term.insertCells(1)
+	}
+	term.ScreenState.writeRune(r)
+	term.lastRune = r
+}
+
+// setModes processes SM and RM arguments.
+func (term *Terminal) setModes(set bool, a []byte) {
+	private := len(a) > 0 && a[0] == '?'
+	n := 0
+	if private {
+		n++
+	}
+	for n < len(a) {
+		mode, m, err := ansi.DecodeMode(private, a[n:])
+		if err != nil || m == 0 {
+			return
+		}
+		n += m
+		term.setMode(mode, set)
+	}
+}
+
+func (term *Terminal) setMode(mode ansi.Mode, set bool) {
+	switch mode {
+	case ansi.ModeInsert:
+		term.insert = set
+	case ansi.ModeNewline:
+		term.newline = set
+	case ansi.ShowCursor:
+		term.Visible = set
+	default:
+		if !term.ScreenState.setMode(mode, set) {
+			term.modes[mode] = set
+		}
+	}
+}
+
+// rowCells returns the cell offset of the cursor, and the offset of the end
+// of its row.
+func (term *Terminal) rowCells() (i, end int, ok bool) {
+	i, ok = term.CellOffset(term.Point)
+	end = term.Y * term.Size.X
+	return i, end, ok
+}
+
+// insertCells inserts n blank cells at the cursor, shifting the rest of the
+// row right.
+func (term *Terminal) insertCells(n int) {
+	i, end, ok := term.rowCells()
+	if !ok {
+		return
+	}
+	if n > end-i {
+		n = end - i
+	}
+	copy(term.Grid.Rune[i+n:end], term.Grid.Rune[i:end-n])
+	copy(term.Grid.Attr[i+n:end], term.Grid.Attr[i:end-n])
+	term.clearRegion(i, i+n)
+}
+
+// deleteCells deletes n cells at the cursor, shifting the rest of the row
+// left, and blanking the end of the row.
+func (term *Terminal) deleteCells(n int) {
+	i, end, ok := term.rowCells()
+	if !ok {
+		return
+	}
+	if n > end-i {
+		n = end - i
+	}
+	copy(term.Grid.Rune[i:end-n], term.Grid.Rune[i+n:end])
+	copy(term.Grid.Attr[i:end-n], term.Grid.Attr[i+n:end])
+	term.clearRegion(end-n, end)
+}
+
+// eraseCells blanks n cells starting at the cursor.
+func (term *Terminal) eraseCells(n int) {
+	i, end, ok := term.rowCells()
+	if !ok {
+		return
+	}
+	if n > end-i {
+		n = end - i
+	}
+	term.clearRegion(i, i+n)
+}
+
+// reply sends the given control sequence to the Replies channel, if any.
+func (term *Terminal) reply(seq ansi.Seq) {
+	if term.Replies != nil {
+		term.Replies <- seq.AppendTo(nil)
+	}
+}
@@ -0,0 +1,236 @@
+package anansi_test
+
+import (
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	. "github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/ansi"
+	anansitest "github.com/jcorbin/anansi/test"
+)
+
+func TestTerminal(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		writes []string
+		lines  []string
+		cursor ansi.Point
+		check  func(t *testing.T, term *Terminal)
+	}{
+		{
+			name:   "hello",
+			writes: []string{"hello\r\nworld"},
+			lines:  []string{"hello     ", "world     ", "          "},
+			cursor: ansi.Pt(6, 2),
+		},
+
+		{
+			name:   "pending wrap",
+			writes: []string{"0123456789"},
+			lines:  []string{"0123456789", "          ", "          "},
+			cursor: ansi.Pt(10, 1),
+		},
+
+		{
+			name:   "wrap",
+			writes: []string{"0123456789X"},
+			lines:  []string{"0123456789", "X         ", "          "},
+			cursor: ansi.Pt(2, 2),
+		},
+
+		{
+			name:   "pending wrap cancelled",
+			writes: []string{"0123456789\rX"},
+			lines:  []string{"X123456789", "          ", "          "},
+			cursor: ansi.Pt(2, 1),
+		},
+
+		{
+			name:   "autowrap off",
+			writes: []string{"\x1b[?7l0123456789AB"},
+			lines:  []string{"012345678B", "          ", "          "},
+			cursor: ansi.Pt(10, 1),
+			check: func(t *testing.T, term *Terminal) {
+				assert.False(t, term.Mode(ansi.ModeAutoWrap))
+			},
+		},
+
+		{
+			name:   "scroll",
+			writes: []string{"1\r\n2\r\n3\r\n4"},
+			lines:  []string{"2         ", "3         ", "4         "},
+			cursor: ansi.Pt(2, 3),
+		},
+
+		{
+			name:   "origin mode",
+			writes: []string{"\x1b[2;3r\x1b[?6h\x1b[HX\x1b[9;1HY"},
+			lines:  []string{"          ", "X         ", "Y         "},
+			cursor: ansi.Pt(2, 3),
+		},
+
+		{
+			name:   "insert mode",
+			writes: []string{"abc\r\x1b[4hX"},
+			lines:  []string{"Xabc      ", "          ", "          "},
+			cursor: ansi.Pt(2, 1),
+		},
+
+		{
+			name:   "insert delete erase",
+			writes: []string{"abcdef\r\x1b[2@\r\n", "abcdef\r\x1b[2P\r\n", "abcdef\r\x1b[2X"},
+			lines:  []string{"  abcdef  ", "cdef      ", "  cdef    "},
+			cursor: ansi.Pt(1, 3),
+		},
+
+		{
+			name:   "repeat",
+			writes: []string{"a\x1b[3b"},
+			lines:  []string{"aaaa      ", "          ", "          "},
+			cursor: ansi.Pt(5, 1),
+		},
+
+		{
+			name:   "tabs",
+			writes: []string{"a\tb\r\n", "\x1b[3g\x1b[4G\x1bH\ra\tb\x1b[2Zc"},
+			lines:  []string{"a       b ", "c  b      ", "          "},
+			cursor: ansi.Pt(2, 2),
+		},
+
+		{
+			name:   "save restore",
+			writes: []string{"\x1b[2;3H\x1b[31m\x1b7\x1b[H\x1b[0mx\x1b8y"},
+			lines:  []string{"x         ", "  \x1b[31my\x1b[0m       ", "          "},
+			cursor: ansi.Pt(4, 2),
+		},
+
+		{
+			name:   "alternate screen",
+			writes: []string{"main\x1b[?1049h\x1b[Halt"},
+			lines:  []string{"alt       ", "          ", "          "},
+			cursor: ansi.Pt(4, 1),
+			check: func(t *testing.T, term *Terminal) {
+				assert.True(t, term.Mode(ansi.ModeAlternateScreen))
+			},
+		},
+
+		{
+			name:   "alternate screen exit",
+			writes: []string{"main\x1b[?1049h\x1b[Halt\x1b[?1049l"},
+			lines:  []string{"main      ", "          ", "          "},
+			cursor: ansi.Pt(5, 1),
+		},
+
+		{
+			name:   "dec special graphics",
+			writes: []string{"\x1b(0lqk\x1b(Bx\r\n", "\x1b)0a\x0eq\x0fq"},
+			lines:  []string{"┌─┐x      ", "a─q       ", "          "},
+			cursor: ansi.Pt(4, 2),
+		},
+
+		{
+			name:   "uk charset",
+			writes: []string{"\x1b(A#1"},
+			lines:  []string{"£1        ", "          ", "          "},
+			cursor: ansi.Pt(3, 1),
+		},
+
+		{
+			name:   "split escape",
+			writes: []string{"\x1b[", "2;3", "H", "x"},
+			lines:  []string{"          ", "  x       ", "          "},
+			cursor: ansi.Pt(4, 2),
+		},
+
+		{
+			name:   "title and cursor style",
+			writes: []string{"\x1b]2;hello\x07\x1b[5 q"},
+			lines:  []string{"          ", "          ", "          "},
+			cursor: ansi.Pt(1, 1),
+			check: func(t *testing.T, term *Terminal) {
+				assert.Equal(t, "hello", term.Title)
+				assert.Equal(t, ansi.CursorBar, term.Shape)
+				assert.True(t, term.Blink)
+			},
+		},
+
+		{
+			name:   "modes",
+			writes: []string{"\x1b[?25l\x1b[?1000;1006h"},
+			lines:  []string{"          ", "          ", "          "},
+			cursor: ansi.Pt(1, 1),
+			check: func(t *testing.T, term *Terminal) {
+				assert.False(t, term.Mode(ansi.ShowCursor))
+				assert.True(t, term.Mode(ansi.ModeMouseVt200))
+				assert.True(t, term.Mode(ansi.ModeMouseSgrExt))
+				assert.False(t, term.Mode(ansi.ModeMouseAnyEvent))
+			},
+		},
+
+		{
+			name:   "reset",
+			writes: []string{"hello\x1b[?7l\x1b[2;3r\x1bc"},
+			lines:  []string{"          ", "          ", "          "},
+			cursor: ansi.Pt(1, 1),
+			check: func(t *testing.T, term *Terminal) {
+				assert.True(t, term.Mode(ansi.ModeAutoWrap))
+			},
+		},
+		{
+			name:   "title",
+			writes: []string{"\x1b]0;ti", "tle\x07hello"},
+			lines:  []string{"hello     ", "          ", "          "},
+			cursor: ansi.Pt(6, 1),
+			check: func(t *testing.T, term *Terminal) {
+				assert.Equal(t, "title", term.Title)
+			},
+		},
+
+		{
+			name:   "malformed title",
+			writes: []string{"\x1b]0;caf\xe9\x07hello", "\r\nworld"},
+			lines:  []string{"hello     ", "world     ", "          "},
+			cursor: ansi.Pt(6, 2),
+			check: func(t *testing.T, term *Terminal) {
+				assert.Equal(t, "caf\xe9", term.Title)
+			},
+		},
+
+		{
+			name:   "malformed escape",
+			writes: []string{"a\x1b\xe9=b"},
+			lines:  []string{"a\ufffdb       ", "          ", "          "},
+			cursor: ansi.Pt(4, 1),
+		},
+	} {
+		t.Run(tc.name, logBuf.With(func(t *testing.T) {
+			var term Terminal
+			term.Resize(image.Pt(10, 3))
+			for _, s := range tc.writes {
+				term.WriteString(s)
+			}
+			assert.Equal(t, tc.lines, anansitest.GridLines(term.Grid, ' '), "expected grid lines")
+			assert.Equal(t, tc.cursor, term.Point, "expected cursor point")
+			if tc.check != nil {
+				tc.check(t, &term)
+			}
+		}))
+	}
+}
+
+func TestTerminal_replies(t *testing.T) {
+	replies := make(chan []byte, 3)
+	var term Terminal
+	term.Replies = replies
+	term.Resize(image.Pt(10, 3))
+	term.WriteString("\x1b[c\x1b[2;3H\x1b[6n\x1b[5n")
+	close(replies)
+
+	var got []string
+	for reply := range replies {
+		got = append(got, string(reply))
+	}
+	assert.Equal(t, []string{"\x1b[?62;22c", "\x1b[2;3R", "\x1b[0n"}, got)
+}
@@ -22,6 +22,7 @@ type Config struct {
 	CPUProfileName string
 	TraceFileName  string
 	MemProfileName string
+	CrashDumpName  string // replay of input leading up to any panic
 	// TODO config for arbitrary pprof profiles
 
 	StartTiming bool // Whether to start and
@@ -45,6 +46,8 @@ func (cfg *Config) AddFlags(f *flag.FlagSet, prefix string) {
 		"enables platform memory profiling")
 	f.StringVar(&cfg.TraceFileName, prefix+"tracefile", cfg.TraceFileName,
 		"enables platform execution tracing")
+	f.StringVar(&cfg.CrashDumpName, prefix+"crashdump", cfg.CrashDumpName,
+		"write a replay of recent input to a file if a panic occurs")
 	if prefix != "" {
 		f.BoolVar(&cfg.StartTiming, prefix+"timing", false,
 			"measure timing from the beginning")
@@ -72,6 +75,9 @@ func (cfg *Config) Merge(other Config) {
 	if other.MemProfileName != "" && cfg.MemProfileName == "" {
 		cfg.MemProfileName = other.MemProfileName
 	}
+	if other.CrashDumpName != "" && cfg.CrashDumpName == "" {
+		cfg.CrashDumpName = other.CrashDumpName
+	}
 	if other.LogTiming {
 		cfg.LogTiming = true
 	}
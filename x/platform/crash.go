@@ -0,0 +1,78 @@
+package platform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"os"
+)
+
+// crashTapeLimit bounds the amount of input kept on the crash tape; once
+// exceeded, the tape is restarted with a new state snapshot.
+const crashTapeLimit = 4 * 1024 * 1024
+
+var errNoCrashTape = errors.New("no input recorded for crash dump")
+
+// crashTape records recent input in memory, after a snapshot of platform
+// state, so that a crash dump may be written in replay file format.
+type crashTape struct {
+	active bool
+	failed bool // state couldn't be encoded, so the tape is off
+	state  bytes.Buffer
+	input  bytes.Buffer
+}
+
+// updateCrashTape (re)starts the crash tape if a crash dump file is configured
+// and it's not running, or has grown too large; it's called at the start of
+// each frame, before polling for input. If platform state can't be encoded,
+// the tape is turned off for good, rather than retrying every frame.
+func (p *Platform) updateCrashTape() {
+	if p.CrashDumpName == "" || p.recording != nil || p.replay != nil || p.crashTape.failed {
+		return
+	}
+	if p.crashTape.active && p.crashTape.input.Len() < crashTapeLimit {
+		return
+	}
+	p.crashTape.active = false
+	p.crashTape.state.Reset()
+	p.crashTape.input.Reset()
+	if err := p.writeState(&p.crashTape.state); err != nil {
+		log.Printf("failed to encode platform state (no crash tape): %v", err)
+		p.crashTape.failed = true
+		p.crashTape.state.Reset()
+		p.events.input.SetRecording(nil)
+		return
+	}
+	p.crashTape.active = true
+	if sz := p.screen.Bounds().Size(); sz.X > 0 && sz.Y > 0 {
+		_ = writeSize(&p.crashTape.input, sz)
+	}
+	p.events.input.SetRecording(&p.crashTape.input)
+}
+
+// CrashDump writes a replay of the input leading up to a crash: the active
+// recording if any, or the crash tape otherwise; it implements
+// anansi.CrashDumper, and is used by Run when a crash dump file is configured.
+func (p *Platform) CrashDump(w io.Writer) error {
+	if p.recording != nil {
+		f, err := os.Open(p.recording.Name())
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, f)
+		return errOr(err, f.Close())
+	}
+	if !p.crashTape.active {
+		return errNoCrashTape
+	}
+	var tmp [8]byte
+	binary.BigEndian.PutUint64(tmp[:], uint64(p.crashTape.state.Len()))
+	for _, b := range [][]byte{tmp[:], p.crashTape.state.Bytes(), p.crashTape.input.Bytes()} {
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package platform
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlatform_CrashDump(t *testing.T) {
+	p, err := New(Config{CrashDumpName: "unused"})
+	require.NoError(t, err)
+	cl := testClient{Num: 42}
+	p.client = &cl
+
+	assert.Equal(t, errNoCrashTape, p.CrashDump(ioutil.Discard), "expected no tape yet")
+	p.updateCrashTape()
+	require.True(t, p.crashTape.active, "expected crash tape to start")
+	cl.Num = 99
+	p.crashTape.input.WriteString("hello")
+
+	f, err := ioutil.TempFile("", "anansi-crash")
+	require.NoError(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	require.NoError(t, p.CrashDump(f))
+	_, err = f.Seek(0, 0)
+	require.NoError(t, err)
+
+	rep, err := readReplay(f)
+	require.NoError(t, err)
+	var input []byte
+	for _, frm := range rep.input {
+		input = append(input, frm.B...)
+	}
+	assert.Equal(t, "hello", string(input))
+	require.NoError(t, p.readState(bytes.NewReader(rep.cereal)))
+	assert.Equal(t, 42, cl.Num, "expected state snapshot from tape start")
+}
+
+func TestPlatform_CrashDump_unencodable(t *testing.T) {
+	p, err := New(Config{CrashDumpName: "unused"})
+	require.NoError(t, err)
+	p.client = ClientFunc(func(*Context) error { return nil }) // funcs can't be gob encoded
+
+	logs := func() int {
+		b, _ := Logs.Contents()
+		return bytes.Count(b, []byte("no crash tape"))
+	}
+	n := logs()
+	for i := 0; i < 3; i++ {
+		p.updateCrashTape()
+	}
+	assert.False(t, p.crashTape.active, "expected no crash tape")
+	assert.Equal(t, 1, logs()-n, "expected failure to be logged once")
+	assert.Equal(t, errNoCrashTape, p.CrashDump(ioutil.Discard))
+}
@@ -17,6 +17,7 @@ type Telemetry struct {
 	FPSEstimate FPSEstimate
 	Timing      TimingData
 	Stalls      StallsData
+	Output      OutputData
 
 	coll telemetryCollector
 }
@@ -74,6 +75,23 @@ type StallsStats struct {
 	Pct  float64
 }
 
+// OutputData stores output throughput data.
+type OutputData struct {
+	Stats OutputStats
+
+	written           int64
+	skipped, deferred int
+}
+
+// OutputStats stores output throughput stats, computed about once a second.
+type OutputStats struct {
+	Time     time.Time
+	Bytes    int64   // bytes written since the prior stats
+	Rate     float64 // bytes per second
+	Skipped  int     // frames skipped due to output budget
+	Deferred int     // frames whose output was left pending, rather than stall
+}
+
 // FPS returns the measured FPS rate if timing collection is enabled, or the
 // current FPSEstimate value otherwise.
 func (tel *Telemetry) FPS() float64 {
@@ -124,6 +142,8 @@ func (tel *Telemetry) update(p *Platform) {
 	tel.coll.Unlock()
 	tel.coll.t = p.Time
 
+	tel.Output.update(p)
+
 	tel.LastTick = p.ticks.Metric
 	if tel.LogTicks {
 		tel.coll.tick = &tel.LastTick
@@ -217,6 +237,27 @@ func (sd *StallsData) update(p *Platform, stalls []time.Duration) {
 	sd.Stats = stats
 }
 
+func (od *OutputData) update(p *Platform) {
+	if od.Stats.Time.IsZero() {
+		od.Stats.Time = p.Time
+		od.written, od.skipped, od.deferred = p.output.Written, p.output.Skipped, p.output.Deferred
+		return
+	}
+	elapsed := p.Time.Sub(od.Stats.Time)
+	if elapsed < time.Second {
+		return
+	}
+	out := p.output
+	od.Stats = OutputStats{
+		Time:     p.Time,
+		Bytes:    out.Written - od.written,
+		Skipped:  out.Skipped - od.skipped,
+		Deferred: out.Deferred - od.deferred,
+	}
+	od.Stats.Rate = float64(od.Stats.Bytes) / elapsed.Seconds()
+	od.written, od.skipped, od.deferred = out.Written, out.Skipped, out.Deferred
+}
+
 func (fe *FPSEstimate) update(p *Platform, delta time.Duration) {
 	fe.data[fe.i] = float64(time.Second) / float64(delta)
 	fe.i = (fe.i + 1) % len(fe.data)
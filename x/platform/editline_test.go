@@ -160,7 +160,7 @@ func TestEditLine(t *testing.T) {
 			},
 			{
 				in:     "hello alice",
-				out:    "\x1b[?25lllo\x1b[Calice\x1b[?25h",
+				out:    "\x1b[?25lllo alice\x1b[?25h",
 				expect: expectResult(""),
 			},
 			{
@@ -178,7 +178,7 @@ func TestEditLine(t *testing.T) {
 			{
 				in: "\x0d",
 				out: "\x1b[?25l" +
-					"\x1b[9D     \x1b[C   ",
+					"\x1b[9D         ",
 				expect: expectResult("hello bob"),
 			},
 		}},
@@ -192,13 +192,13 @@ func TestEditLine(t *testing.T) {
 			},
 			{
 				in:     "hello alice",
-				out:    "\x1b[?25lllo\x1b[Calice\x1b[?25h",
+				out:    "\x1b[?25lllo alice\x1b[?25h",
 				expect: expectResult(""),
 			},
 			{
 				in: "\x1b[5D",
 				out: "\x1b[?25l" +
-					"\x1b[9De\x1b[Clo alice" +
+					"\x1b[9Dello alice" +
 					"\x1b[5D\x1b[?25h",
 				expect: expectResult(""),
 			},
@@ -224,7 +224,7 @@ func TestEditLine(t *testing.T) {
 			{
 				in: "\x0d",
 				out: "\x1b[?25l" +
-					"\x1b[9D  \x1b[C      ",
+					"\x1b[9D         ",
 				expect: expectResult("hello, alice!"),
 			},
 		}},
@@ -444,6 +444,12 @@ func (hud *HUD) drawStallsDetail(ctx *Context) {
 		hud.detailRow(ctx, "∑ t", stats.Sum.String())
 		hud.detailRow(ctx, "% t", fmt.Sprintf("%.2f%%", 100.0*stats.Pct))
 	}
+
+	hud.detailHeader(ctx, "# Output:")
+	out := ctx.Telemetry.Output.Stats
+	hud.detailRow(ctx, "rate", fmt.Sprintf("%.0fB/s", out.Rate))
+	hud.detailRow(ctx, "skipped", strconv.Itoa(out.Skipped))
+	hud.detailRow(ctx, "deferred", strconv.Itoa(out.Deferred))
 }
 
 func (hud *HUD) drawButton(ctx *Context, box ansi.Rectangle, label string) {
@@ -25,6 +25,28 @@ func FrameRate(fps int) Option {
 	})
 }
 
+// OutputBudget limits output to the given number of bytes per second, on
+// average; frames are skipped while the budget is overdrawn, and output that
+// would block is left pending (coalescing frames until it's written) rather
+// than stalling the frame loop. See anansi.Output.Budget and NoStall.
+func OutputBudget(bytesPerSecond int) Option {
+	return optionFunc(func(p *Platform) error {
+		p.output.Budget = bytesPerSecond
+		p.output.NoStall = bytesPerSecond > 0
+		return nil
+	})
+}
+
+// ResizePoll causes the terminal size to be polled at the given interval, in
+// addition to being read after SIGWINCH; useful for terminals behind tools
+// that don't forward it. See anansi.ResizeWatcher.Poll.
+func ResizePoll(interval time.Duration) Option {
+	return optionFunc(func(p *Platform) error {
+		p.resizes.Poll = interval
+		return nil
+	})
+}
+
 func hasConfig(opts []Option) bool {
 	for _, opt := range opts {
 		if _, isConfig := opt.(Config); isConfig {
@@ -27,13 +27,19 @@ func MustRun(f *os.File, run func(*Platform) error, opts ...Option) {
 }
 
 // Run is a convenience wrapper that calls the run function with a newly
-// created Platform activated under a newly constructed anansi.Term.
+// created Platform activated under a newly constructed anansi.Term. Any panic
+// is recovered by an anansi.Crash context, and reported after restoring the
+// terminal; a crash dump is written if Config.CrashDumpName is set.
 func Run(f *os.File, run func(*Platform) error, opts ...Option) error {
 	p, err := New(opts...)
 	if err != nil {
 		return err
 	}
-	return anansi.NewTerm(f, p).With(func(_ *anansi.Term) error {
+	crash := anansi.Crash{
+		DumpFile: p.CrashDumpName,
+		Dumpers:  []anansi.CrashDumper{p},
+	}
+	return anansi.NewTerm(f, &crash, p).With(func(_ *anansi.Term) error {
 		return run(p)
 	})
 }
@@ -61,6 +67,8 @@ func New(opts ...Option) (*Platform, error) {
 		p.events.input,
 		p.output,
 		p.ticks,
+		&p.resizes,
+		&p.jobs,
 	)
 
 	timingPeriod := defaultFrameRate / 4
@@ -110,8 +118,14 @@ type Platform struct {
 	events Events
 	ticks  *Ticks
 
+	cellQuery  bool // need to query cell size by XTWINOPS
+	imageProbe int  // frames left to await replies to the inline image probe
+
+	resizes   anansi.ResizeWatcher
+	jobs      anansi.JobControl
 	recording *os.File
 	replay    *replay
+	crashTape crashTape
 	bgworkers []BackgroundWorker
 
 	State
@@ -130,6 +144,16 @@ type State struct {
 	Paused   bool
 	LastTime time.Time
 	LastSize image.Point
+
+	// CellSize is the terminal cell size in pixels, as of the last resize;
+	// it's zero until known, which may take a query round trip for
+	// terminals that don't report pixel sizes with their window size.
+	CellSize image.Point
+
+	// Images are the inline image protocols supported by the terminal, as
+	// detected from the environment and a probe sent when entering it;
+	// probe replies may take a frame or more to arrive.
+	Images ansi.ImageProtocols
 }
 
 // Client runs under a platform, processing input and generating output within
@@ -180,49 +204,71 @@ func (p *Platform) Run(client Client) (err error) {
 	signal.Notify(stopSig, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
 	defer signal.Stop(stopSig)
 
-	resizeSig := make(chan os.Signal, 1)
-	signal.Notify(resizeSig, syscall.SIGWINCH)
-	defer signal.Stop(resizeSig)
-
 	log.Printf("running %T", p.client)
 	defer func() {
 		log.Printf("run done: %v", err)
 	}()
 
 	for p.Time = time.Now(); !p.Time.IsZero(); p.Time = p.ticks.Wait(p.Time) {
-		// update performance data
-		p.Telemetry.update(p)
-
-		ctx := p.Context()
-
-		// poll for events and input
-		for polling := true; polling; {
-			select {
-			case sig := <-stopSig:
-				ctx.Err = errOr(ctx.Err, signalError{sig})
-			case <-resizeSig:
-				ctx.Err = errOr(ctx.Err, p.readSize())
-			default:
-				ctx.Err = errOr(ctx.Err, p.events.Poll())
-				polling = false
-			}
+		if err = p.runLockedFrame(stopSig); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		// run current frame update
-		if ctx.Update(); ctx.Err == nil {
-			ctx.Err = p.output.Flush(ctx.Output)
-		}
+// runLockedFrame runs a frame while holding the job control lock, so that any
+// suspension happens between frames; the lock is released even if the frame
+// panics, since a recovering context (e.g. anansi.Crash) may then need to exit
+// job control.
+func (p *Platform) runLockedFrame(stopSig <-chan os.Signal) error {
+	p.jobs.Lock()
+	defer p.jobs.Unlock()
+	return p.runFrame(stopSig)
+}
 
-		// notify background workers
-		for i := 0; ctx.Err == nil && i < len(p.bgworkers); i++ {
-			ctx.Err = p.bgworkers[i].Notify()
+// runFrame runs a single round of the Run loop.
+func (p *Platform) runFrame(stopSig <-chan os.Signal) error {
+	// update performance data
+	p.Telemetry.update(p)
+
+	ctx := p.Context()
+
+	// poll for events and input
+	p.updateCrashTape()
+	for polling := true; polling; {
+		select {
+		case sig := <-stopSig:
+			ctx.Err = errOr(ctx.Err, signalError{sig})
+		case <-p.resizes.C():
+			ctx.Err = errOr(ctx.Err, p.readSize())
+		case <-p.jobs.Resumed():
+			ctx.Redraw = true
+		default:
+			ctx.Err = errOr(ctx.Err, p.events.Poll())
+			polling = false
 		}
+	}
+	p.readCellSize()
+	p.readSyncReply()
+	p.readImageReply()
+
+	// run current frame update, resuming any query left pending by NoStall
+	if ctx.Update(); ctx.Err == nil && p.buf.Len() > 0 {
+		ctx.Err = p.output.Flush(&p.buf)
+	} else if ctx.Err == nil {
+		ctx.Err = p.output.Flush(ctx.Output)
+	}
+	if ctx.Err == nil {
+		ctx.Err = p.queryCellSize()
+	}
 
-		if ctx.Err != nil {
-			return ctx.Err
-		}
+	// notify background workers
+	for i := 0; ctx.Err == nil && i < len(p.bgworkers); i++ {
+		ctx.Err = p.bgworkers[i].Notify()
 	}
-	return nil
+
+	return ctx.Err
 }
 
 // Context returns a new Context bound to the platform.
@@ -239,15 +285,91 @@ func (p *Platform) readSize() error {
 	if p.term == nil {
 		return errNoTerm
 	}
-	sz, err := p.term.Size()
+	sz, pixels, err := p.term.WindowSize()
 	if err == nil {
-		if p.screen.Resize(sz) && p.recording != nil {
+		if cell := anansi.CellSize(sz, pixels); cell != image.ZP {
+			p.CellSize = cell
+		} else {
+			p.cellQuery = true
+		}
+		if p.screen.Resize(sz) {
 			err = p.recordSize()
 		}
 	}
 	return err
 }
 
+// queryCellSize flushes an XTWINOPS cell size query through output, if the
+// last resize couldn't determine it, once any prior output has been written;
+// the reply is handled by readCellSize. Any query left pending by NoStall
+// remains in buf until resumed; one skipped due to Budget is retried later.
+func (p *Platform) queryCellSize() error {
+	if !p.cellQuery || p.output.Pending() {
+		return nil
+	}
+	p.cellQuery = false
+	p.buf.WriteSeq(ansi.XTWINOPS.WithInts(16))
+	err := p.output.Flush(&p.buf)
+	if !p.output.Pending() && p.buf.Len() > 0 {
+		p.buf.Reset()
+		p.cellQuery = true
+	}
+	return err
+}
+
+// readCellSize consumes any XTWINOPS cell size reports from the event queue.
+func (p *Platform) readCellSize() {
+	for id, kind := range p.events.Type {
+		if kind != EventEscape || p.events.esc[id] != ansi.XTWINOPS {
+			continue
+		}
+		if op, size, _, err := ansi.DecodeWindowReport(p.events.arg[id]); err == nil && op == 6 {
+			p.CellSize = size
+			p.events.Type[id] = EventNone
+		}
+	}
+}
+
+// readSyncReply consumes any synchronized output mode report from the event
+// queue, enabling synchronized output if supported.
+func (p *Platform) readSyncReply() {
+	for id, kind := range p.events.Type {
+		if kind == EventEscape && p.output.Sync.ProbeReply(p.events.esc[id], p.events.arg[id]) {
+			p.events.Type[id] = EventNone
+		}
+	}
+}
+
+// imageProbeFrames is how many frames replies to the inline image probe are
+// awaited, so that a terminal that never answers its DA query doesn't cause
+// later DA replies (meant for the application) to be consumed.
+const imageProbeFrames = 60
+
+// readImageReply consumes any replies to the inline image probe from the
+// event queue, adding supported protocols to Images; it gives up on them after
+// imageProbeFrames calls.
+func (p *Platform) readImageReply() {
+	for id, kind := range p.events.Type {
+		if p.imageProbe == 0 {
+			return
+		}
+		if kind != EventEscape {
+			continue
+		}
+		switch e, a := p.events.esc[id], p.events.arg[id]; {
+		case e == ansi.Escape(0x9F) && bytes.HasPrefix(a, []byte("G")), // kitty graphics APC
+			e == ansi.DA:
+			if p.Images.ProbeReply(e, a) {
+				p.imageProbe = 0
+			}
+			p.events.Type[id] = EventNone
+		}
+	}
+	if p.imageProbe > 0 {
+		p.imageProbe--
+	}
+}
+
 // Enter applies terminal context, including raw mode and ansi mode sequences,
 // wires up input, output, and initializes the tick controller.
 func (p *Platform) Enter(term *anansi.Term) error {
@@ -261,14 +383,18 @@ func (p *Platform) Enter(term *anansi.Term) error {
 	if err := p.term.SetRaw(true); err != nil {
 		return err
 	}
+	p.screen.OutputProcessing = p.term.OutputProcessing()
 
 	p.buf.Write(p.modes.Set)
+	p.buf.Write(ansi.AppendSyncProbe(nil))
+	p.buf.Write(ansi.AppendImageProbe(nil))
+	p.imageProbe = imageProbeFrames
+	p.Images.ProbeEnv(os.Getenv)
 	if p.buf.Len() > 0 {
 		if _, err := p.buf.WriteTo(term.File); err != nil {
 			return err
 		}
 	}
-
 	if err := p.termContext.Enter(term); err != nil {
 		return err
 	}
@@ -298,6 +424,7 @@ func (p *Platform) Exit(term *anansi.Term) (err error) {
 
 	p.buf.WriteSGR(p.screen.CursorState.MergeSGR(0))
 	p.buf.WriteSeq(p.screen.CursorState.Show())
+	p.buf.WriteSeq(ansi.CursorDefault.Style(false)) // any UserCursor shape
 	p.buf.Write(p.modes.Reset)
 	if p.buf.Len() > 0 {
 		_, err = p.buf.WriteTo(term.File)
@@ -322,8 +449,8 @@ func (ctx *Context) Update() {
 	ctx.Output.Reset()
 	outBounds := ctx.Output.Bounds()
 
-	// Ctrl-L forces a size refresh
-	ctx.Redraw = ctx.Input.CountRune('\x0c') > 0
+	// Ctrl-L forces a size refresh (as does resuming after job control stop)
+	ctx.Redraw = ctx.Redraw || ctx.Input.CountRune('\x0c') > 0
 
 	// Resize causes a redraw
 	ctx.Redraw = ctx.Redraw ||
@@ -363,21 +490,14 @@ func (ctx *Context) runClient() error {
 	return err
 }
 
-// Suspend restores terminal context to pre-platform-run settings, suspends the
-// current process, and then restores platform terminal context once resumed;
-// returns any error preventing any of that.
+// Suspend stops the current process, as if by Ctrl-Z in a cooked terminal: a
+// SIGTSTP is raised, and then handled by the platform's anansi.JobControl
+// once the current frame is done, restoring terminal context to pre-platform
+// settings while stopped, and then restoring platform terminal context (and
+// redrawing) once resumed.
 func (p *Platform) Suspend() error {
-	return p.term.Without(func(_ *anansi.Term) error {
-		cont := make(chan os.Signal)
-		signal.Notify(cont, syscall.SIGCONT)
-		log.Printf("suspending")
-		if err := syscall.Kill(0, syscall.SIGTSTP); err != nil {
-			return err
-		}
-		<-cont
-		log.Printf("resumed")
-		return nil
-	})
+	log.Printf("suspending")
+	return syscall.Kill(syscall.Getpid(), syscall.SIGTSTP)
 }
 
 type signalError struct{ sig os.Signal }
@@ -1,7 +1,17 @@
 package platform
 
 import (
+	"bytes"
 	"image"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jcorbin/anansi"
+	"github.com/jcorbin/anansi/ansi"
 )
 
 func NewTest(size image.Point, client Client) *Platform {
@@ -10,3 +20,104 @@ func NewTest(size image.Point, client Client) *Platform {
 	p.State.LastSize = size
 	return &p
 }
+
+func TestPlatform_readCellSize(t *testing.T) {
+	p := NewTest(image.Pt(80, 24), nil)
+	p.events.Load([]byte("a\x1b[8;24;80t\x1b[6;16;8tb"))
+	p.readCellSize()
+	assert.Equal(t, image.Pt(8, 16), p.CellSize)
+	assert.Equal(t, []EventType{EventRune, EventEscape, EventNone, EventRune}, p.events.Type)
+}
+
+func TestPlatform_readSyncReply(t *testing.T) {
+	p := NewTest(image.Pt(80, 24), nil)
+	p.output = anansi.NewOutput(nil)
+	p.events.Load([]byte("a\x1b[?2026;2$yb"))
+	p.readSyncReply()
+	assert.Equal(t, ansi.SyncMode, p.output.Sync)
+	assert.Equal(t, []EventType{EventRune, EventNone, EventRune}, p.events.Type)
+}
+
+func TestPlatform_readImageReply(t *testing.T) {
+	p := NewTest(image.Pt(80, 24), nil)
+	p.imageProbe = imageProbeFrames
+	p.events.Load([]byte("a\x1b_Gi=31;OK\x1b\\\x1b[?62;4;22cb"))
+	p.readImageReply()
+	assert.Equal(t, ansi.ImageKitty|ansi.ImageSixel, p.Images)
+	assert.Equal(t, 0, p.imageProbe, "expected probe done")
+	assert.Equal(t, []EventType{EventRune, EventNone, EventNone, EventRune}, p.events.Type)
+
+	p.events.Load([]byte("\x1b[?62;22c"))
+	p.readImageReply()
+	assert.Equal(t, []EventType{EventEscape}, p.events.Type, "expected later DA to be left alone")
+}
+
+func TestPlatform_readImageReply_timeout(t *testing.T) {
+	p := NewTest(image.Pt(80, 24), nil)
+	p.imageProbe = imageProbeFrames
+	for i := 0; i < imageProbeFrames; i++ {
+		p.events.Load([]byte("a"))
+		p.readImageReply()
+	}
+	assert.Equal(t, 0, p.imageProbe, "expected probe given up")
+	p.events.Load([]byte("\x1b[?62;22c"))
+	p.readImageReply()
+	assert.Equal(t, ansi.ImageProtocols(0), p.Images)
+	assert.Equal(t, []EventType{EventEscape}, p.events.Type, "expected unanswered probe to leave DA alone")
+}
+
+func TestPlatform_queryCellSize(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+	defer w.Close()
+
+	p := NewTest(image.Pt(80, 24), nil)
+	p.output = anansi.NewOutput(w)
+	p.output.NoStall = true
+
+	frame := bytes.NewReader(make([]byte, 1<<20))
+	require.NoError(t, p.output.Flush(frame))
+	require.True(t, p.output.Pending(), "expected frame to be left pending")
+	p.cellQuery = true
+	require.NoError(t, p.queryCellSize())
+	assert.True(t, p.cellQuery, "expected query to wait for pending output")
+
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		_, err := out.ReadFrom(r)
+		done <- err
+	}()
+	for p.output.Pending() {
+		require.NoError(t, p.output.Flush(frame))
+	}
+	require.NoError(t, p.queryCellSize())
+	assert.False(t, p.cellQuery, "expected query to be queued")
+	for p.output.Pending() {
+		require.NoError(t, p.output.Flush(&p.buf))
+	}
+	w.Close()
+	require.NoError(t, <-done)
+	assert.Equal(t, 1<<20+5, out.Len(), "expected frame and query")
+	assert.Equal(t, "\x1b[16t", string(out.Bytes()[1<<20:]), "expected query after frame")
+}
+
+func TestPlatform_runLockedFrame_panic(t *testing.T) {
+	p, err := New(Config{})
+	require.NoError(t, err)
+	p.client = ClientFunc(func(*Context) error { panic("oops") })
+	assert.PanicsWithValue(t, "oops", func() { _ = p.runLockedFrame(nil) })
+
+	locked := make(chan struct{})
+	go func() {
+		p.jobs.Lock()
+		p.jobs.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("expected job control lock to be released")
+	}
+}
@@ -131,10 +131,7 @@ func (rep *replay) drawOverlay(ctx *Context) {
 
 	if rep.mouse.Mouse != ZM {
 		// TODO better mouse cursor drawing
-		if i, ok := ctx.Output.CellOffset(rep.mouse.Point); ok {
-			ctx.Output.Grid.Rune[i] = 'X'
-			ctx.Output.Grid.Attr[i] = buttonAttrs[rep.mouse.State.ButtonID()]
-		}
+		ctx.Output.Grid.Set(rep.mouse.Point, 'X', buttonAttrs[rep.mouse.State.ButtonID()])
 	}
 
 	// TODO OSD for keyboard events?
@@ -153,6 +150,7 @@ func (rep *replay) next() bool {
 
 func (p *Platform) setRecording(f *os.File, err error) {
 	p.events.input.SetRecording(nil)
+	p.crashTape.active = false
 	if p.recording != nil {
 		if err := p.recording.Close(); err != nil {
 			log.Printf("failed to close record file %q: %v", p.recording.Name(), err)
@@ -215,16 +213,24 @@ func (p *Platform) toggleRecRep() error {
 }
 
 func (p *Platform) recordSize() error {
-	// APC "resize:" width "," height ST
+	sz := p.screen.Bounds().Size()
 	if p.recording != nil {
-		sz := p.screen.Bounds().Size()
-		if _, err := fmt.Fprintf(p.recording, "\x1b_resize:%d,%d\x1b\\", sz.X, sz.Y); err != nil {
+		if err := writeSize(p.recording, sz); err != nil {
 			return fmt.Errorf("failed to record size: %v", err)
 		}
 	}
+	if p.crashTape.active {
+		_ = writeSize(&p.crashTape.input, sz)
+	}
 	return nil
 }
 
+func writeSize(w io.Writer, sz image.Point) error {
+	// APC "resize:" width "," height ST
+	_, err := fmt.Fprintf(w, "\x1b_resize:%d,%d\x1b\\", sz.X, sz.Y)
+	return err
+}
+
 func parseSize(b []byte) (pt image.Point, err error) {
 	i := bytes.IndexByte(b, ',')
 	if i < 0 {